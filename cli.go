@@ -2,10 +2,14 @@ package main
 
 import (
 	"bufio"
+	"bytes"
 	"errors"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"os"
+	"os/exec"
+	"path/filepath"
 	"strings"
 	"time"
 
@@ -23,7 +27,23 @@ var (
 	ErrPathAndPaths      = errors.New("--path and --paths can not be given at the same time")
 	ErrRecursiveWithName = errors.New("--recursive can not be used with a name")
 	ErrExportWithName    = errors.New("--export can not be used with a name")
+	ErrFormatWithName    = errors.New("--format can not be used with a name")
+	ErrExportWithFormat  = errors.New("--export and --format can not be given at the same time")
 	ErrTooManyArguments  = errors.New("too many arguments")
+	ErrRequireSrcAndDst  = errors.New("src and dst are required")
+	ErrRequireName       = errors.New("name is required")
+	ErrMetadataWithText  = errors.New("--metadata can not be used with --format text")
+	ErrRequireSchema     = errors.New("--schema is required")
+	ErrFilterWithName    = errors.New("filters can not be used with a name")
+	ErrNegativeDepth     = errors.New("--depth must not be negative")
+	ErrRequirePattern    = errors.New("pattern is required")
+
+	ErrRequireVersionOrAt       = errors.New("either --version or --at is required")
+	ErrOverwriteAndSkipExisting = errors.New("--overwrite and --skip-existing can not be given at the same time")
+	ErrRevealWithNoDecrypt      = errors.New("--reveal and --no-decrypt can not be given at the same time")
+	ErrEncryptWithoutReveal     = errors.New("--encrypt requires --reveal")
+	ErrEncryptWithExport        = errors.New("--encrypt can not be used with --export")
+	ErrEncryptWithName          = errors.New("--encrypt can not be used with a name")
 )
 
 // A CLI is the ssmenv command line interface.
@@ -56,12 +76,35 @@ func (c CLI) out() io.Writer {
 	return c.output
 }
 
+func (c CLI) errOut() io.Writer {
+	if c.output == nil {
+		return os.Stderr
+	}
+	return c.output
+}
+
 func (c CLI) newCmd() *cobra.Command {
 	cmd := c.newRootCmd()
 	cmd.AddCommand(c.newExecCmd())
 	cmd.AddCommand(c.newGetCmd())
 	cmd.AddCommand(c.newSetCmd())
 	cmd.AddCommand(c.newReplaceCmd())
+	cmd.AddCommand(c.newImportCmd())
+	cmd.AddCommand(c.newBackupCmd())
+	cmd.AddCommand(c.newRestoreCmd())
+	cmd.AddCommand(c.newDiffCmd())
+	cmd.AddCommand(c.newDeleteCmd())
+	cmd.AddCommand(c.newCopyCmd())
+	cmd.AddCommand(c.newMoveCmd())
+	cmd.AddCommand(c.newSyncCmd())
+	cmd.AddCommand(c.newHistoryCmd())
+	cmd.AddCommand(c.newRollbackCmd())
+	cmd.AddCommand(c.newLabelCmd())
+	cmd.AddCommand(c.newUnlabelCmd())
+	cmd.AddCommand(c.newExpiringCmd())
+	cmd.AddCommand(c.newValidateCmd())
+	cmd.AddCommand(c.newListCmd())
+	cmd.AddCommand(c.newSearchCmd())
 	return cmd
 }
 
@@ -73,7 +116,9 @@ func (c CLI) newRootCmd() *cobra.Command {
 		RunE:  c.runRoot,
 	}
 	cmd.PersistentFlags().String("region", "", "The region to use. Overrides config/env settings.")
+	cmd.PersistentFlags().String("profile", "", "The profile to use from your credential file.")
 	cmd.PersistentFlags().String("path", "", "The hierarchy for the parameter.")
+	cmd.PersistentFlags().String("config", "", "The config file. Defaults to $SSMENV_CONFIG or ~/.ssmenv.yml.")
 	cmd.PersistentFlags().Bool("debug", false, "debug mode")
 	panicIfError(cmd.PersistentFlags().MarkHidden("debug"))
 	cmd.Flags().Bool("version", false, "show version")
@@ -89,6 +134,13 @@ func (c CLI) newExecCmd() *cobra.Command {
 	}
 	cmd.Flags().StringSlice("paths", []string{}, "Comma separated multiple paths.")
 	cmd.Flags().Bool("recursive", false, "Retrieve all parameters within a hierarchy.")
+	cmd.Flags().Bool("redact-output", false, "Replace SecureString values in the output of the command with ****.")
+	cmd.Flags().StringSlice(
+		"redact-encodings",
+		[]string{lib.EncodingBase64, lib.EncodingURL},
+		"Comma separated encodings of SecureString values to redact as well: base64, url or hex.",
+	)
+	addFilterFlags(cmd)
 	cmd.Flags().SetInterspersed(false)
 	return cmd
 }
@@ -102,6 +154,13 @@ func (c CLI) newGetCmd() *cobra.Command {
 	}
 	cmd.Flags().Bool("recursive", false, "Retrieve all parameters within a hierarchy.")
 	cmd.Flags().Bool("export", false, "Print export statements for shells.")
+	cmd.Flags().String("format", lib.FormatText, "text, json or yaml. StringList values are arrays in json and yaml.")
+	cmd.Flags().Bool("metadata", false, "Print the type, tier and size of each parameter in json and yaml.")
+	cmd.Flags().Bool("reveal", false, "Print SecureString values in plaintext instead of masking them.")
+	cmd.Flags().Bool("no-decrypt", false, "Retrieve SecureString values without decryption. They are always masked.")
+	cmd.Flags().Bool("encrypt", false, "Encrypt the output with a passphrase to import it. It requires --reveal.")
+	cmd.Flags().String("passphrase-file", "", "Read the passphrase from the file instead of $SSMENV_PASSPHRASE.")
+	addFilterFlags(cmd)
 	return cmd
 }
 
@@ -112,6 +171,10 @@ func (c CLI) newSetCmd() *cobra.Command {
 		Long:  `Set parameters.`,
 		RunE:  c.runSet,
 	}
+	addUpdateFlags(cmd)
+	addKMSFlags(cmd)
+	addTierFlags(cmd)
+	addSchemaFlags(cmd)
 	return cmd
 }
 
@@ -123,9 +186,285 @@ func (c CLI) newReplaceCmd() *cobra.Command {
 		RunE:  c.runReplace,
 	}
 	cmd.Flags().Bool("recursive", false, "Replace all parameters within a hierarchy.")
+	addUpdateFlags(cmd)
+	addKMSFlags(cmd)
+	addTierFlags(cmd)
+	addSchemaFlags(cmd)
+	addReplaceFlags(cmd)
+	return cmd
+}
+
+func (c CLI) newImportCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "import [flags] [file]",
+		Short: "Import parameters from a file",
+		Long:  `Import parameters from a dotenv, JSON, YAML or Java properties file.`,
+		RunE:  c.runImport,
+	}
+	cmd.Flags().String("format", "", "dotenv, json, yaml or properties. Guessed from the file extension by default.")
+	cmd.Flags().StringSlice("secure-keys", []string{}, "Comma separated keys to be SecureString.")
+	cmd.Flags().StringSlice("secure-suffixes", []string{}, "Comma separated key suffixes to be SecureString.")
+	cmd.Flags().Bool("replace", false, "Replace all the parameters of the given path.")
+	cmd.Flags().Bool("recursive", false, "Replace all parameters within a hierarchy. Used with --replace.")
+	cmd.Flags().String("passphrase-file", "", "Read the passphrase from the file instead of $SSMENV_PASSPHRASE.")
+	addUpdateFlags(cmd)
+	addKMSFlags(cmd)
+	addTierFlags(cmd)
+	addSchemaFlags(cmd)
+	addReplaceFlags(cmd)
+	return cmd
+}
+
+func (c CLI) newDeleteCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "delete [flags] [name ...]",
+		Short: "Delete parameters",
+		Long:  `Delete the given parameters, or all the parameters of the given path.`,
+		RunE:  c.runDelete,
+	}
+	cmd.Flags().Bool("recursive", false, "Delete all parameters within a hierarchy.")
+	cmd.Flags().String("match", "", "Delete only the names matching the glob pattern.")
+	addUpdateFlags(cmd)
+	addDeleteFlags(cmd)
+	return cmd
+}
+
+func (c CLI) newBackupCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "backup [flags]",
+		Short: "Back up parameters with their metadata",
+		Long:  `Back up parameters with their metadata as JSON.`,
+		RunE:  c.runBackup,
+	}
+	cmd.Flags().Bool("recursive", false, "Back up all parameters within a hierarchy.")
+	cmd.Flags().StringP("output", "o", "", "Write to the file instead of stdout.")
+	cmd.Flags().Bool("encrypt", false, "Encrypt the backup with a passphrase.")
+	cmd.Flags().String("passphrase-file", "", "Read the passphrase from the file instead of $SSMENV_PASSPHRASE.")
+	return cmd
+}
+
+func (c CLI) newRestoreCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "restore [flags] [file]",
+		Short: "Restore parameters from a backup",
+		Long:  `Restore parameters with their metadata from a backup.`,
+		RunE:  c.runRestore,
+	}
+	cmd.Flags().StringArray("path-rewrite", []string{}, "Rewrite the leading path of names: /old=/new")
+	cmd.Flags().String("passphrase-file", "", "Read the passphrase from the file instead of $SSMENV_PASSPHRASE.")
+	return cmd
+}
+
+func (c CLI) newDiffCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "diff [flags] src dst",
+		Short: "Print differences between two paths or files",
+		Long: `Print differences between two paths, regions, accounts or files.
+An argument beginning with "file:" or without a leading slash is a file, otherwise a path.`,
+		RunE: c.runDiff,
+	}
+	cmd.Flags().Bool("recursive", false, "Compare all parameters within hierarchies.")
+	addSideFlags(cmd)
+	cmd.Flags().Bool("show-values", false, "Show SecureString values instead of masking them.")
+	cmd.Flags().String("format", lib.FormatText, "text or json.")
+	return cmd
+}
+
+func (c CLI) newCopyCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "copy [flags] src dst",
+		Short: "Copy parameters",
+		Long: `Copy a parameter, or all the parameters of a path, with their metadata and tags.
+src is a parameter, or a path if no such parameter exists or --recursive is given.`,
+		RunE: c.runCopy,
+	}
+	addCopyFlags(cmd)
+	return cmd
+}
+
+func (c CLI) newMoveCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "move [flags] src dst",
+		Short: "Move parameters",
+		Long: `Move a parameter, or all the parameters of a path, with their metadata and tags.
+The source parameters are deleted only after all of them are copied.`,
+		RunE: c.runMove,
+	}
+	addCopyFlags(cmd)
+	return cmd
+}
+
+func (c CLI) newSyncCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "sync [flags] src dst",
+		Short: "Sync parameters from a path to another",
+		Long: `Update the parameters of dst to be the same as src, which may be in another region or account.
+Parameters only in dst are deleted only with --delete.`,
+		RunE: c.runSync,
+	}
+	cmd.Flags().Bool("recursive", false, "Sync all parameters within hierarchies.")
+	cmd.Flags().Bool("delete", false, "Delete parameters which do not exist in src.")
+	cmd.Flags().StringArray("exclude", []string{}, "Leave names relative to src and dst matching the glob pattern.")
+	addSideFlags(cmd)
+	addUpdateFlags(cmd)
+	addKMSFlags(cmd)
+	addReplaceFlags(cmd)
+	return cmd
+}
+
+func (c CLI) newHistoryCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "history [flags] name",
+		Short: "Print the versions of a parameter",
+		Long:  `Print every version of a parameter with its date, modifying user, type, labels and value.`,
+		RunE:  c.runHistory,
+	}
+	cmd.Flags().Bool("show-values", false, "Show SecureString values instead of masking them.")
+	cmd.Flags().String("format", lib.FormatText, "text or json.")
+	return cmd
+}
+
+func (c CLI) newRollbackCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "rollback [flags] [name]",
+		Short: "Roll back parameters to a previous version or point in time",
+		Long: `Roll back a parameter to a previous version, or the parameters of the given path to a point in time.
+Parameters created after the point in time are deleted.`,
+		RunE: c.runRollback,
+	}
+	cmd.Flags().Int64("version", 0, "The version to roll back a parameter to.")
+	cmd.Flags().String("at", "", "The point in time to roll back to, e.g. 2006-01-02T15:04Z.")
+	cmd.Flags().Bool("recursive", false, "Roll back all parameters within a hierarchy.")
+	addUpdateFlags(cmd)
+	addDeleteFlags(cmd)
+	return cmd
+}
+
+func (c CLI) newLabelCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "label [flags] name[:version] label ...",
+		Short: "Attach labels to a version of a parameter",
+		Long:  `Attach labels to a version of a parameter, or to the latest version if no version is given.`,
+		RunE:  c.runLabel,
+	}
+}
+
+func (c CLI) newUnlabelCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "unlabel [flags] name[:version] label ...",
+		Short: "Detach labels from a parameter",
+		Long:  `Detach labels from a version of a parameter, or from whichever versions have them if no version is given.`,
+		RunE:  c.runUnlabel,
+	}
+}
+
+func (c CLI) newExpiringCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "expiring [flags]",
+		Short: "Print parameters which expire soon",
+		Long:  `Print the parameters of the given path whose Expiration policies expire soon.`,
+		RunE:  c.runExpiring,
+	}
+	cmd.Flags().Bool("recursive", false, "Retrieve all parameters within a hierarchy.")
+	cmd.Flags().String("within", "14d", "Print parameters which expire within the duration, e.g. 14d or 12h.")
+	cmd.Flags().String("format", lib.FormatText, "text or json.")
+	return cmd
+}
+
+func (c CLI) newValidateCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "validate [flags]",
+		Short: "Check parameters against a schema",
+		Long:  `Check the parameters of the given path against a schema, and print the violations if any.`,
+		RunE:  c.runValidate,
+	}
+	cmd.Flags().Bool("recursive", false, "Check all parameters within a hierarchy.")
+	addSchemaFlags(cmd)
+	return cmd
+}
+
+func (c CLI) newListCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:     "ls [flags]",
+		Aliases: []string{"list"},
+		Short:   "Print the hierarchy of parameters without values",
+		Long: `Print the parameters within the hierarchy of the given path as a tree.
+Only their metadata are retrieved, so values are never decrypted.`,
+		RunE: c.runList,
+	}
+	cmd.Flags().Int("depth", 0, "Print parameters down to the depth. Unlimited if 0.")
+	addMetadataFilterFlags(cmd)
+	return cmd
+}
+
+func (c CLI) newSearchCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "search [flags] pattern",
+		Short: "Search parameters by names and values",
+		Long: `Print the names of the parameters within the hierarchy of the given path which match the pattern.
+Matches in values are printed with their context masked.`,
+		RunE: c.runSearch,
+	}
+	cmd.Flags().Bool("values", false, "Search decrypted values as well as names.")
+	cmd.Flags().Bool("regex", false, "Treat the pattern as a regular expression.")
 	return cmd
 }
 
+func addCopyFlags(cmd *cobra.Command) {
+	cmd.Flags().Bool("recursive", false, "Copy all parameters within a hierarchy.")
+	cmd.Flags().Bool("overwrite", false, "Overwrite existing parameters of dst.")
+	cmd.Flags().Bool("skip-existing", false, "Skip existing parameters of dst.")
+	addSideFlags(cmd)
+	addKMSFlags(cmd)
+}
+
+func addUpdateFlags(cmd *cobra.Command) {
+	cmd.Flags().Bool("dry-run", false, "Print the plan without writing anything. Exits with 2 if changes are pending.")
+}
+
+func addKMSFlags(cmd *cobra.Command) {
+	cmd.Flags().String("kms-key-id", "", "The KMS key to encrypt SecureString parameters. Overrides the config file.")
+}
+
+// addTierFlags adds the flags of tiers and parameter policies, which require Advanced.
+func addTierFlags(cmd *cobra.Command) {
+	cmd.Flags().String("tier", "", "Standard, Advanced, Intelligent-Tiering, or auto to upgrade values over 4KB.")
+	cmd.Flags().String("expires-in", "", "Expire the parameters put after the duration, e.g. 90d. Makes them Advanced.")
+	cmd.Flags().String("notify-before", "", "Notify the duration before the expiration. Used with --expires-in.")
+	cmd.Flags().String("notify-no-change", "", "Notify if the parameters put are not changed for the duration.")
+}
+
+func addFilterFlags(cmd *cobra.Command) {
+	addMetadataFilterFlags(cmd)
+	cmd.Flags().String("label", "", "Select the versions with the label. Parameters without it are omitted.")
+}
+
+// addMetadataFilterFlags adds the filters which do not require retrieving values.
+func addMetadataFilterFlags(cmd *cobra.Command) {
+	cmd.Flags().StringArray("tag", []string{}, "Select parameters with the tag: key=value")
+	cmd.Flags().String("type", "", "Select parameters of the type: String, StringList or SecureString.")
+	cmd.Flags().String("tier", "", "Select parameters of the tier: Standard or Advanced.")
+	cmd.Flags().String("modified-since", "", "Select parameters modified since the time or the duration ago, e.g. 7d.")
+	cmd.Flags().String("name-contains", "", "Select parameters whose names relative to the path contain the string.")
+}
+
+func addSchemaFlags(cmd *cobra.Command) {
+	cmd.Flags().String("schema", "", "The schema file which parameters must satisfy.")
+}
+
+func addDeleteFlags(cmd *cobra.Command) {
+	cmd.Flags().BoolP("yes", "y", false, "Delete parameters without confirmation.")
+	cmd.Flags().Int("max-deletes", 0, "Refuse to delete more parameters than this. 0 means no limit.")
+	cmd.Flags().StringArray("protect", []string{}, "Refuse to delete parameters matching the glob pattern.")
+}
+
+func addReplaceFlags(cmd *cobra.Command) {
+	addDeleteFlags(cmd)
+	cmd.Flags().Float64("max-delete-fraction", 0.5, "Refuse to delete more than this fraction of existing parameters.")
+	cmd.Flags().Bool("force", false, "Ignore --max-delete-fraction.")
+	cmd.Flags().Duration("delete-grace-period", 0, "Wait between putting new parameters and deleting obsolete ones.")
+}
+
 func (c CLI) runRoot(cmd *cobra.Command, _ []string) error {
 	versionFlag, err := cmd.Flags().GetBool("version")
 	if err != nil {
@@ -159,44 +498,97 @@ func (c CLI) runExec(cmd *cobra.Command, args []string) error {
 		}
 	}
 
-	recursive, err := cmd.Flags().GetBool("recursive")
-	if err != nil {
+	var execOpts lib.ExecOptions
+	if execOpts.Recursive, err = cmd.Flags().GetBool("recursive"); err != nil {
+		return err
+	}
+	if execOpts.Filter, err = getFilter(cmd); err != nil {
+		return err
+	}
+	if execOpts.RedactOutput, err = cmd.Flags().GetBool("redact-output"); err != nil {
 		return err
 	}
+	if execOpts.RedactOutput {
+		if execOpts.RedactEncodings, err = cmd.Flags().GetStringSlice("redact-encodings"); err != nil {
+			return err
+		}
+	}
 
 	cmd.SilenceUsage = true
-	return lib.Exec(svc, paths, recursive, args)
+	return silenceExitError(cmd, lib.Exec(svc, paths, execOpts, args))
 }
 
+// nolint: gocyclo
 func (c CLI) runGet(cmd *cobra.Command, args []string) error {
 	svc, path, err := getPersistentFlags(cmd)
 	if err != nil {
 		return err
 	}
 
-	recursive, err := cmd.Flags().GetBool("recursive")
-	if err != nil {
+	var getOpts lib.GetOptions
+	if getOpts.Recursive, err = cmd.Flags().GetBool("recursive"); err != nil {
 		return err
 	}
-
-	exportFlag, err := cmd.Flags().GetBool("export")
-	if err != nil {
+	if getOpts.Export, err = cmd.Flags().GetBool("export"); err != nil {
+		return err
+	}
+	if getOpts.Format, err = cmd.Flags().GetString("format"); err != nil {
+		return err
+	}
+	if getOpts.Metadata, err = cmd.Flags().GetBool("metadata"); err != nil {
+		return err
+	}
+	if getOpts.Filter, err = getFilter(cmd); err != nil {
 		return err
 	}
+	if getOpts.Reveal, err = cmd.Flags().GetBool("reveal"); err != nil {
+		return err
+	}
+	if getOpts.NoDecrypt, err = cmd.Flags().GetBool("no-decrypt"); err != nil {
+		return err
+	}
+	if getOpts.Passphrase, err = getEncryptPassphrase(cmd); err != nil {
+		return err
+	}
+
+	if getOpts.Export && getOpts.Format != lib.FormatText {
+		return ErrExportWithFormat
+	}
+	if getOpts.Reveal && getOpts.NoDecrypt {
+		return ErrRevealWithNoDecrypt
+	}
+	if getOpts.Metadata && getOpts.Format == lib.FormatText {
+		return ErrMetadataWithText
+	}
+	if getOpts.Passphrase != nil && !getOpts.Reveal {
+		return ErrEncryptWithoutReveal
+	}
+	if getOpts.Passphrase != nil && getOpts.Export {
+		return ErrEncryptWithExport
+	}
 
 	switch len(args) {
 	case 0:
 		cmd.SilenceUsage = true
-		return lib.GetByPath(c.out(), svc, path, recursive, exportFlag)
+		return lib.GetByPath(c.out(), svc, path, getOpts)
 	case 1:
-		if recursive {
+		if getOpts.Recursive {
 			return ErrRecursiveWithName
 		}
-		if exportFlag {
+		if getOpts.Export {
 			return ErrExportWithName
 		}
+		if getOpts.Format != lib.FormatText {
+			return ErrFormatWithName
+		}
+		if !getOpts.Filter.Empty() {
+			return ErrFilterWithName
+		}
+		if getOpts.Passphrase != nil {
+			return ErrEncryptWithName
+		}
 		cmd.SilenceUsage = true
-		return lib.GetByName(c.out(), svc, path, args[0])
+		return lib.GetByName(c.out(), svc, path, args[0], getOpts)
 	default:
 		return ErrTooManyArguments
 	}
@@ -215,8 +607,13 @@ func (c CLI) runSet(cmd *cobra.Command, args []string) error {
 		}
 	}
 
+	opts, err := c.getUpdateOptions(cmd)
+	if err != nil {
+		return err
+	}
+
 	cmd.SilenceUsage = true
-	return lib.Set(c.out(), svc, path, args)
+	return silenceChangesPending(cmd, lib.Set(c.out(), svc, path, args, opts))
 }
 
 func (c CLI) runReplace(cmd *cobra.Command, args []string) error {
@@ -237,44 +634,940 @@ func (c CLI) runReplace(cmd *cobra.Command, args []string) error {
 		}
 	}
 
+	opts, err := c.getUpdateOptions(cmd)
+	if err != nil {
+		return err
+	}
+
 	cmd.SilenceUsage = true
-	return lib.Replace(c.out(), svc, path, recursive, args)
+	return silenceChangesPending(cmd, lib.Replace(c.out(), svc, path, recursive, args, opts))
 }
 
-func getPersistentFlags(cmd *cobra.Command) (*ssm.SSM, string, error) {
-	region, err := cmd.Flags().GetString("region")
+// nolint: gocyclo
+func (c CLI) runImport(cmd *cobra.Command, args []string) error {
+	svc, path, err := getPersistentFlags(cmd)
 	if err != nil {
-		return nil, "", err
+		return err
+	}
+	if path == "" {
+		return lib.ErrRequirePath
 	}
 
-	debug, err := cmd.Flags().GetBool("debug")
+	format, err := cmd.Flags().GetString("format")
 	if err != nil {
-		return nil, "", err
+		return err
 	}
 
-	svc := ssm.New(newSession(region, debug))
+	var secure lib.SecureMatcher
+	secure.Keys, err = cmd.Flags().GetStringSlice("secure-keys")
+	if err != nil {
+		return err
+	}
+	secure.Suffixes, err = cmd.Flags().GetStringSlice("secure-suffixes")
+	if err != nil {
+		return err
+	}
 
-	path, err := cmd.Flags().GetString("path")
+	replace, err := cmd.Flags().GetBool("replace")
 	if err != nil {
-		return nil, "", err
+		return err
 	}
 
-	return svc, path, nil
+	recursive, err := cmd.Flags().GetBool("recursive")
+	if err != nil {
+		return err
+	}
+
+	if format == "" {
+		format = lib.FormatDotenv
+		if len(args) > 0 {
+			format = lib.FormatFromFilename(args[0])
+		}
+	}
+
+	passphrase, err := getPassphrase(cmd)
+	if err != nil {
+		return err
+	}
+
+	f, err := c.openInput(args)
+	if err != nil {
+		return err
+	}
+	defer f.Close() // nolint: errcheck
+
+	r, err := lib.DecryptReader(f, passphrase)
+	if err != nil {
+		return err
+	}
+
+	exprs, err := lib.ParseFile(r, format, secure)
+	if err != nil {
+		return err
+	}
+
+	opts, err := c.getUpdateOptions(cmd)
+	if err != nil {
+		return err
+	}
+
+	cmd.SilenceUsage = true
+	if replace {
+		return silenceChangesPending(cmd, lib.Replace(c.out(), svc, path, recursive, exprs, opts))
+	}
+	return silenceChangesPending(cmd, lib.Set(c.out(), svc, path, exprs, opts))
 }
 
-func newSession(region string, debug bool) *session.Session {
-	config := aws.NewConfig()
+func (c CLI) runDelete(cmd *cobra.Command, args []string) error {
+	svc, path, err := getPersistentFlags(cmd)
+	if err != nil {
+		return err
+	}
 
-	if region != "" {
-		config.WithRegion(region)
+	recursive, err := cmd.Flags().GetBool("recursive")
+	if err != nil {
+		return err
 	}
 
-	if debug {
-		config.WithLogger(stderrlogger.New())
-		config.WithLogLevel(aws.LogDebugWithHTTPBody | aws.LogDebugWithRequestErrors | aws.LogDebugWithRequestRetries)
+	match, err := cmd.Flags().GetString("match")
+	if err != nil {
+		return err
+	}
+
+	if recursive && len(args) > 0 {
+		return ErrRecursiveWithName
+	}
+
+	opts, err := c.getUpdateOptions(cmd)
+	if err != nil {
+		return err
+	}
+
+	cmd.SilenceUsage = true
+	return silenceChangesPending(cmd, lib.Delete(c.out(), svc, path, recursive, match, args, opts))
+}
+
+func (c CLI) runBackup(cmd *cobra.Command, args []string) error {
+	svc, path, err := getPersistentFlags(cmd)
+	if err != nil {
+		return err
+	}
+
+	recursive, err := cmd.Flags().GetBool("recursive")
+	if err != nil {
+		return err
+	}
+
+	output, err := cmd.Flags().GetString("output")
+	if err != nil {
+		return err
+	}
+
+	passphrase, err := getEncryptPassphrase(cmd)
+	if err != nil {
+		return err
+	}
+
+	if len(args) > 0 {
+		return ErrTooManyArguments
+	}
+
+	w, err := c.createOutput(output)
+	if err != nil {
+		return err
+	}
+
+	cmd.SilenceUsage = true
+	if err := lib.Backup(w, svc, path, recursive, passphrase); err != nil {
+		w.Close() // nolint: errcheck
+		return err
+	}
+	return w.Close()
+}
+
+func (c CLI) runRestore(cmd *cobra.Command, args []string) error {
+	svc, _, err := getPersistentFlags(cmd)
+	if err != nil {
+		return err
+	}
+
+	rewrites, err := cmd.Flags().GetStringArray("path-rewrite")
+	if err != nil {
+		return err
+	}
+
+	passphrase, err := getPassphrase(cmd)
+	if err != nil {
+		return err
+	}
+
+	r, err := c.openInput(args)
+	if err != nil {
+		return err
+	}
+	defer r.Close() // nolint: errcheck
+
+	cmd.SilenceUsage = true
+	return lib.Restore(c.out(), svc, r, rewrites, passphrase)
+}
+
+func (c CLI) runDiff(cmd *cobra.Command, args []string) error {
+	if len(args) != 2 {
+		return ErrRequireSrcAndDst
+	}
+
+	recursive, err := cmd.Flags().GetBool("recursive")
+	if err != nil {
+		return err
+	}
+
+	showValues, err := cmd.Flags().GetBool("show-values")
+	if err != nil {
+		return err
+	}
+
+	format, err := cmd.Flags().GetString("format")
+	if err != nil {
+		return err
+	}
+
+	src, err := snapshot(cmd, "src", args[0], recursive)
+	if err != nil {
+		return err
+	}
+
+	dst, err := snapshot(cmd, "dst", args[1], recursive)
+	if err != nil {
+		return err
+	}
+
+	cmd.SilenceUsage = true
+	return lib.Diff(c.out(), src, dst, showValues, format)
+}
+
+func (c CLI) runCopy(cmd *cobra.Command, args []string) error {
+	srcSvc, dstSvc, opts, err := getCopyFlags(cmd, args)
+	if err != nil {
+		return err
+	}
+
+	cmd.SilenceUsage = true
+	return lib.Copy(c.out(), srcSvc, args[0], dstSvc, args[1], opts)
+}
+
+func (c CLI) runMove(cmd *cobra.Command, args []string) error {
+	srcSvc, dstSvc, opts, err := getCopyFlags(cmd, args)
+	if err != nil {
+		return err
+	}
+
+	cmd.SilenceUsage = true
+	return lib.Move(c.out(), srcSvc, args[0], dstSvc, args[1], opts)
+}
+
+func (c CLI) runSync(cmd *cobra.Command, args []string) error {
+	if len(args) != 2 {
+		return ErrRequireSrcAndDst
+	}
+
+	var syncOpts lib.SyncOptions
+	var err error
+	if syncOpts.Recursive, err = cmd.Flags().GetBool("recursive"); err != nil {
+		return err
+	}
+	if syncOpts.Delete, err = cmd.Flags().GetBool("delete"); err != nil {
+		return err
+	}
+	if syncOpts.Exclude, err = cmd.Flags().GetStringArray("exclude"); err != nil {
+		return err
+	}
+
+	srcSvc, err := newService(cmd, "src")
+	if err != nil {
+		return err
+	}
+	dstSvc, err := newService(cmd, "dst")
+	if err != nil {
+		return err
+	}
+
+	opts, err := c.getUpdateOptions(cmd)
+	if err != nil {
+		return err
+	}
+
+	cmd.SilenceUsage = true
+	return silenceChangesPending(cmd, lib.Sync(c.out(), srcSvc, args[0], dstSvc, args[1], syncOpts, opts))
+}
+
+func (c CLI) runHistory(cmd *cobra.Command, args []string) error {
+	svc, path, err := getPersistentFlags(cmd)
+	if err != nil {
+		return err
+	}
+
+	showValues, err := cmd.Flags().GetBool("show-values")
+	if err != nil {
+		return err
+	}
+
+	format, err := cmd.Flags().GetString("format")
+	if err != nil {
+		return err
+	}
+
+	switch len(args) {
+	case 0:
+		return ErrRequireName
+	case 1:
+		cmd.SilenceUsage = true
+		return lib.History(c.out(), svc, path, args[0], showValues, format)
+	default:
+		return ErrTooManyArguments
+	}
+}
+
+// nolint: gocyclo
+func (c CLI) runRollback(cmd *cobra.Command, args []string) error {
+	svc, path, err := getPersistentFlags(cmd)
+	if err != nil {
+		return err
+	}
+
+	var rbOpts lib.RollbackOptions
+	if rbOpts.Version, err = cmd.Flags().GetInt64("version"); err != nil {
+		return err
+	}
+	if rbOpts.Recursive, err = cmd.Flags().GetBool("recursive"); err != nil {
+		return err
+	}
+
+	at, err := cmd.Flags().GetString("at")
+	if err != nil {
+		return err
+	}
+	if (rbOpts.Version > 0) == (at != "") {
+		return ErrRequireVersionOrAt
+	}
+	if at != "" {
+		if rbOpts.At, err = lib.ParseTime(at); err != nil {
+			return err
+		}
+	}
+
+	var name string
+	switch len(args) {
+	case 0:
+		if rbOpts.Version > 0 {
+			return ErrRequireName
+		}
+	case 1:
+		if rbOpts.Recursive {
+			return ErrRecursiveWithName
+		}
+		name = args[0]
+	default:
+		return ErrTooManyArguments
+	}
+
+	opts, err := c.getUpdateOptions(cmd)
+	if err != nil {
+		return err
+	}
+
+	cmd.SilenceUsage = true
+	return silenceChangesPending(cmd, lib.Rollback(c.out(), svc, path, name, rbOpts, opts))
+}
+
+func (c CLI) runLabel(cmd *cobra.Command, args []string) error {
+	svc, path, err := getPersistentFlags(cmd)
+	if err != nil {
+		return err
+	}
+
+	if len(args) < 2 {
+		return lib.ErrRequireNameAndLabel
+	}
+
+	cmd.SilenceUsage = true
+	return lib.Label(c.out(), svc, path, args[0], args[1:])
+}
+
+func (c CLI) runUnlabel(cmd *cobra.Command, args []string) error {
+	svc, path, err := getPersistentFlags(cmd)
+	if err != nil {
+		return err
+	}
+
+	if len(args) < 2 {
+		return lib.ErrRequireNameAndLabel
+	}
+
+	cmd.SilenceUsage = true
+	return lib.Unlabel(c.out(), svc, path, args[0], args[1:])
+}
+
+func (c CLI) runExpiring(cmd *cobra.Command, args []string) error {
+	svc, path, err := getPersistentFlags(cmd)
+	if err != nil {
+		return err
+	}
+
+	if len(args) > 0 {
+		return ErrTooManyArguments
+	}
+
+	recursive, err := cmd.Flags().GetBool("recursive")
+	if err != nil {
+		return err
+	}
+
+	within, err := getDurationFlag(cmd, "within")
+	if err != nil {
+		return err
+	}
+
+	format, err := cmd.Flags().GetString("format")
+	if err != nil {
+		return err
+	}
+
+	cmd.SilenceUsage = true
+	return lib.Expiring(c.out(), svc, path, recursive, within, format)
+}
+
+func (c CLI) runValidate(cmd *cobra.Command, args []string) error {
+	svc, path, err := getPersistentFlags(cmd)
+	if err != nil {
+		return err
+	}
+
+	if len(args) > 0 {
+		return ErrTooManyArguments
+	}
+
+	recursive, err := cmd.Flags().GetBool("recursive")
+	if err != nil {
+		return err
+	}
+
+	schema, err := getSchema(cmd)
+	if err != nil {
+		return err
+	}
+	if schema == nil {
+		return ErrRequireSchema
+	}
+
+	cmd.SilenceUsage = true
+	return lib.Validate(svc, path, recursive, schema)
+}
+
+func (c CLI) runList(cmd *cobra.Command, args []string) error {
+	svc, path, err := getPersistentFlags(cmd)
+	if err != nil {
+		return err
+	}
+
+	if len(args) > 0 {
+		return ErrTooManyArguments
+	}
+
+	depth, err := cmd.Flags().GetInt("depth")
+	if err != nil {
+		return err
+	}
+	if depth < 0 {
+		return ErrNegativeDepth
+	}
+
+	filter, err := getFilter(cmd)
+	if err != nil {
+		return err
+	}
+
+	cmd.SilenceUsage = true
+	return lib.List(c.out(), svc, path, depth, filter)
+}
+
+func (c CLI) runSearch(cmd *cobra.Command, args []string) error {
+	svc, path, err := getPersistentFlags(cmd)
+	if err != nil {
+		return err
+	}
+
+	switch len(args) {
+	case 0:
+		return ErrRequirePattern
+	case 1:
+	default:
+		return ErrTooManyArguments
+	}
+
+	var opts lib.SearchOptions
+	if opts.Values, err = cmd.Flags().GetBool("values"); err != nil {
+		return err
+	}
+	if opts.Regex, err = cmd.Flags().GetBool("regex"); err != nil {
+		return err
+	}
+
+	cmd.SilenceUsage = true
+	return lib.Search(c.out(), svc, path, args[0], opts)
+}
+
+// getCopyFlags reads the arguments and the flags added by addCopyFlags.
+// nolint: gocyclo
+func getCopyFlags(cmd *cobra.Command, args []string) (*ssm.SSM, *ssm.SSM, lib.CopyOptions, error) {
+	var opts lib.CopyOptions
+
+	if len(args) != 2 {
+		return nil, nil, opts, ErrRequireSrcAndDst
+	}
+
+	var err error
+	if opts.Recursive, err = cmd.Flags().GetBool("recursive"); err != nil {
+		return nil, nil, opts, err
+	}
+	if opts.Overwrite, err = cmd.Flags().GetBool("overwrite"); err != nil {
+		return nil, nil, opts, err
+	}
+	if opts.SkipExisting, err = cmd.Flags().GetBool("skip-existing"); err != nil {
+		return nil, nil, opts, err
+	}
+	if opts.Overwrite && opts.SkipExisting {
+		return nil, nil, opts, ErrOverwriteAndSkipExisting
+	}
+	if opts.SameSide, err = isSameSide(cmd); err != nil {
+		return nil, nil, opts, err
+	}
+	if opts.KeyID, opts.PathKeyIDs, err = getKeyIDs(cmd); err != nil {
+		return nil, nil, opts, err
+	}
+
+	srcSvc, err := newService(cmd, "src")
+	if err != nil {
+		return nil, nil, opts, err
+	}
+	dstSvc, err := newService(cmd, "dst")
+	if err != nil {
+		return nil, nil, opts, err
+	}
+	return srcSvc, dstSvc, opts, nil
+}
+
+// isSameSide reports whether src and dst are in the same region with the same profile.
+func isSameSide(cmd *cobra.Command) (bool, error) {
+	for _, name := range []string{"region", "profile"} {
+		src, err := getSideFlag(cmd, "src", name)
+		if err != nil {
+			return false, err
+		}
+		dst, err := getSideFlag(cmd, "dst", name)
+		if err != nil {
+			return false, err
+		}
+		if src != dst {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// filePrefix marks an argument of diff as a file, which may be an absolute path.
+const filePrefix = "file:"
+
+// snapshot returns the parameters of the path, or of the file if the argument is not a path.
+func snapshot(cmd *cobra.Command, side string, arg string, recursive bool) (lib.Snapshot, error) {
+	if strings.HasPrefix(arg, filePrefix) || !strings.HasPrefix(arg, "/") {
+		arg = strings.TrimPrefix(arg, filePrefix)
+		f, err := os.Open(arg)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close() // nolint: errcheck
+		return lib.SnapshotFile(f, lib.FormatFromFilename(arg))
+	}
+
+	svc, err := newService(cmd, side)
+	if err != nil {
+		return nil, err
+	}
+	return lib.SnapshotPath(svc, arg, recursive)
+}
+
+// openInput opens the file given as the only argument, or stdin without arguments.
+func (c CLI) openInput(args []string) (io.ReadCloser, error) {
+	switch len(args) {
+	case 0:
+		return ioutil.NopCloser(c.in()), nil
+	case 1:
+		return os.Open(args[0])
+	default:
+		return nil, ErrTooManyArguments
+	}
+}
+
+// createOutput creates the file with private permission, or returns stdout if the file is empty.
+func (c CLI) createOutput(file string) (io.WriteCloser, error) {
+	if file == "" {
+		return nopWriteCloser{c.out()}, nil
+	}
+	return os.OpenFile(file, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+}
+
+type nopWriteCloser struct {
+	io.Writer
+}
+
+func (nopWriteCloser) Close() error {
+	return nil
+}
+
+func getPersistentFlags(cmd *cobra.Command) (*ssm.SSM, string, error) {
+	svc, err := newService(cmd, "")
+	if err != nil {
+		return nil, "", err
+	}
+
+	path, err := cmd.Flags().GetString("path")
+	if err != nil {
+		return nil, "", err
+	}
+
+	return svc, path, nil
+}
+
+// newService returns a client for --region and --profile,
+// or for --src-region and --src-profile if the side is "src", and so on.
+func newService(cmd *cobra.Command, side string) (*ssm.SSM, error) {
+	region, err := getSideFlag(cmd, side, "region")
+	if err != nil {
+		return nil, err
+	}
+
+	profile, err := getSideFlag(cmd, side, "profile")
+	if err != nil {
+		return nil, err
+	}
+
+	debug, err := cmd.Flags().GetBool("debug")
+	if err != nil {
+		return nil, err
+	}
+
+	return ssm.New(newSession(region, profile, debug)), nil
+}
+
+// getSideFlag returns --side-name if it is given, otherwise --name.
+func getSideFlag(cmd *cobra.Command, side string, name string) (string, error) {
+	if side != "" {
+		value, err := cmd.Flags().GetString(side + "-" + name)
+		if err != nil {
+			return "", err
+		}
+		if value != "" {
+			return value, nil
+		}
+	}
+	return cmd.Flags().GetString(name)
+}
+
+// addSideFlags adds --src-region, --src-profile, --dst-region and --dst-profile.
+func addSideFlags(cmd *cobra.Command) {
+	for _, side := range []string{"src", "dst"} {
+		cmd.Flags().String(side+"-region", "", "The region of "+side+". Overrides --region.")
+		cmd.Flags().String(side+"-profile", "", "The profile of "+side+". Overrides --profile.")
+	}
+}
+
+// getUpdateOptions reads the flags added by addUpdateFlags, addDeleteFlags and addReplaceFlags,
+// and the ones read by getPutOptions.
+func (c CLI) getUpdateOptions(cmd *cobra.Command) (lib.UpdateOptions, error) {
+	var opts lib.UpdateOptions
+	var err error
+
+	opts.DryRun, err = cmd.Flags().GetBool("dry-run")
+	if err != nil {
+		return opts, err
+	}
+
+	if err = getPutOptions(cmd, &opts); err != nil {
+		return opts, err
+	}
+
+	if cmd.Flags().Lookup("yes") == nil {
+		return opts, nil
+	}
+
+	yes, err := cmd.Flags().GetBool("yes")
+	if err != nil {
+		return opts, err
+	}
+	if !yes && c.isTerminal() {
+		opts.Confirm = c.confirmDeletes
+	}
+
+	opts.MaxDeletes, err = cmd.Flags().GetInt("max-deletes")
+	if err != nil {
+		return opts, err
+	}
+
+	opts.Protect, err = cmd.Flags().GetStringArray("protect")
+	if err != nil {
+		return opts, err
+	}
+
+	if cmd.Flags().Lookup("force") == nil {
+		return opts, nil
+	}
+
+	return opts, getReplaceOptions(cmd, &opts)
+}
+
+// getReplaceOptions reads the flags added by addReplaceFlags.
+func getReplaceOptions(cmd *cobra.Command, opts *lib.UpdateOptions) error {
+	var err error
+
+	opts.MaxDeleteFraction, err = cmd.Flags().GetFloat64("max-delete-fraction")
+	if err != nil {
+		return err
+	}
+
+	opts.Force, err = cmd.Flags().GetBool("force")
+	if err != nil {
+		return err
+	}
+
+	opts.DeleteGracePeriod, err = cmd.Flags().GetDuration("delete-grace-period")
+	return err
+}
+
+// getPutOptions reads the flags added by addKMSFlags, addTierFlags and addSchemaFlags.
+// nolint: gocyclo
+func getPutOptions(cmd *cobra.Command, opts *lib.UpdateOptions) error {
+	var err error
+
+	if cmd.Flags().Lookup("kms-key-id") != nil {
+		if opts.KeyID, opts.PathKeyIDs, err = getKeyIDs(cmd); err != nil {
+			return err
+		}
+	}
+
+	if cmd.Flags().Lookup("schema") != nil {
+		if opts.Schema, err = getSchema(cmd); err != nil {
+			return err
+		}
+	}
+
+	if cmd.Flags().Lookup("tier") != nil {
+		if opts.Tier, err = cmd.Flags().GetString("tier"); err != nil {
+			return err
+		}
+		if opts.ExpiresIn, err = getDurationFlag(cmd, "expires-in"); err != nil {
+			return err
+		}
+		if opts.NotifyBefore, err = getDurationFlag(cmd, "notify-before"); err != nil {
+			return err
+		}
+		if opts.NotifyNoChange, err = getDurationFlag(cmd, "notify-no-change"); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// getFilter reads the flags added by addFilterFlags or addMetadataFilterFlags.
+func getFilter(cmd *cobra.Command) (lib.Filter, error) {
+	var filter lib.Filter
+
+	tags, err := cmd.Flags().GetStringArray("tag")
+	if err != nil {
+		return filter, err
+	}
+	if filter.Tags, err = lib.ParseTags(tags); err != nil {
+		return filter, err
+	}
+	if filter.Type, err = cmd.Flags().GetString("type"); err != nil {
+		return filter, err
+	}
+	if filter.Tier, err = cmd.Flags().GetString("tier"); err != nil {
+		return filter, err
+	}
+	if cmd.Flags().Lookup("label") != nil {
+		if filter.Label, err = cmd.Flags().GetString("label"); err != nil {
+			return filter, err
+		}
+	}
+	if filter.NameContains, err = cmd.Flags().GetString("name-contains"); err != nil {
+		return filter, err
+	}
+	filter.ModifiedSince, err = getModifiedSince(cmd)
+	return filter, err
+}
+
+// getModifiedSince reads --modified-since, which is a time or a duration ago, or returns the zero time.
+func getModifiedSince(cmd *cobra.Command) (time.Time, error) {
+	since, err := cmd.Flags().GetString("modified-since")
+	if err != nil || since == "" {
+		return time.Time{}, err
+	}
+	if ago, perr := lib.ParseDuration(since); perr == nil {
+		return time.Now().Add(-ago), nil
+	}
+	return lib.ParseTime(since)
+}
+
+// getSchema loads --schema, or returns nil if it is not given.
+func getSchema(cmd *cobra.Command) (*lib.Schema, error) {
+	file, err := cmd.Flags().GetString("schema")
+	if err != nil || file == "" {
+		return nil, err
+	}
+	return lib.LoadSchema(file)
+}
+
+// getDurationFlag returns the duration of the flag, which may be given in days, or 0 if it is not given.
+func getDurationFlag(cmd *cobra.Command, name string) (time.Duration, error) {
+	value, err := cmd.Flags().GetString(name)
+	if err != nil || value == "" {
+		return 0, err
+	}
+	return lib.ParseDuration(value)
+}
+
+// isTerminal reports whether stdin is a terminal.
+func (c CLI) isTerminal() bool {
+	if c.input != nil {
+		return false
+	}
+	info, err := os.Stdin.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+func (c CLI) confirmDeletes(names []string) (bool, error) {
+	w := c.errOut()
+	fmt.Fprintln(w, "The following parameters will be deleted:")
+	for _, name := range names {
+		fmt.Fprintln(w, "  "+name)
+	}
+	fmt.Fprint(w, "Are you sure? [y/N] ")
+
+	answer, err := bufio.NewReader(c.in()).ReadString('\n')
+	if err != nil && err != io.EOF {
+		return false, err
+	}
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	return answer == "y" || answer == "yes", nil
+}
+
+// silenceChangesPending suppresses the message of lib.ErrChangesPending, which is reported by the exit status.
+func silenceChangesPending(cmd *cobra.Command, err error) error {
+	if err == lib.ErrChangesPending {
+		cmd.SilenceErrors = true
+	}
+	return err
+}
+
+// silenceExitError does not print the error of a command which exits non-zero, since ssmenv exits with its status.
+func silenceExitError(cmd *cobra.Command, err error) error {
+	if _, ok := err.(*exec.ExitError); ok {
+		cmd.SilenceErrors = true
+	}
+	return err
+}
+
+// getKeyIDs reads --kms-key-id and the KMS keys by path of the config file.
+func getKeyIDs(cmd *cobra.Command) (string, map[string]string, error) {
+	keyID, err := cmd.Flags().GetString("kms-key-id")
+	if err != nil {
+		return "", nil, err
+	}
+
+	config, err := loadConfig(cmd)
+	if err != nil {
+		return "", nil, err
+	}
+	return keyID, config.KMSKeyIDs, nil
+}
+
+// loadConfig loads --config, $SSMENV_CONFIG or ~/.ssmenv.yml, the last of which may not exist.
+func loadConfig(cmd *cobra.Command) (lib.Config, error) {
+	file, err := cmd.Flags().GetString("config")
+	if err != nil {
+		return lib.Config{}, err
+	}
+	if file == "" {
+		file = os.Getenv("SSMENV_CONFIG")
+	}
+	if file != "" {
+		return lib.LoadConfig(file, false)
+	}
+
+	home := os.Getenv("HOME")
+	if home == "" {
+		return lib.Config{}, nil
+	}
+	return lib.LoadConfig(filepath.Join(home, ".ssmenv.yml"), true)
+}
+
+// getEncryptPassphrase reads the passphrase if --encrypt is given, or returns nil.
+func getEncryptPassphrase(cmd *cobra.Command) ([]byte, error) {
+	encrypt, err := cmd.Flags().GetBool("encrypt")
+	if err != nil || !encrypt {
+		return nil, err
+	}
+
+	passphrase, err := getPassphrase(cmd)
+	if err != nil {
+		return nil, err
+	}
+	if len(passphrase) == 0 {
+		return nil, lib.ErrRequirePassphrase
+	}
+	return passphrase, nil
+}
+
+// getPassphrase reads the passphrase from --passphrase-file or $SSMENV_PASSPHRASE.
+func getPassphrase(cmd *cobra.Command) ([]byte, error) {
+	file, err := cmd.Flags().GetString("passphrase-file")
+	if err != nil {
+		return nil, err
+	}
+
+	if file == "" {
+		return []byte(os.Getenv("SSMENV_PASSPHRASE")), nil
+	}
+
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	return bytes.TrimRight(data, "\r\n"), nil
+}
+
+func newSession(region string, profile string, debug bool) *session.Session {
+	config := aws.NewConfig()
+
+	if region != "" {
+		config.WithRegion(region)
+	}
+
+	if debug {
+		config.WithLogger(stderrlogger.New())
+		config.WithLogLevel(aws.LogDebugWithHTTPBody | aws.LogDebugWithRequestErrors | aws.LogDebugWithRequestRetries)
+	}
+
+	if profile == "" {
+		return session.Must(session.NewSession(config))
 	}
 
-	return session.Must(session.NewSession(config))
+	return session.Must(session.NewSessionWithOptions(session.Options{
+		Config:            *config,
+		Profile:           profile,
+		SharedConfigState: session.SharedConfigEnable,
+	}))
 }
 
 func readExprs(r io.Reader) ([]string, error) {
@@ -286,7 +1579,7 @@ func readExprs(r io.Reader) ([]string, error) {
 		for scanner.Scan() {
 			line := scanner.Text()
 			line = strings.TrimSpace(line)
-			if line == "" || strings.HasPrefix(line, "#") {
+			if line == "" || strings.HasPrefix(line, "#") && !lib.IsAnnotation(line) {
 				continue
 			}
 			exprs = append(exprs, line)
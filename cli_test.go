@@ -2,13 +2,21 @@ package main
 
 import (
 	"bytes"
+	"encoding/json"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
 	"sort"
 	"strings"
+	"syscall"
 	"testing"
+	"time"
 
+	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/service/ssm"
 	"github.com/m4i/ssmenv/lib"
 	"github.com/mattn/go-shellwords"
@@ -87,7 +95,7 @@ func TestMain(m *testing.M) {
 
 	debug = os.Getenv("SSMENV_TEST_DEBUG") == "1"
 
-	svc = ssm.New(newSession(region, debug))
+	svc = ssm.New(newSession(region, "", debug))
 
 	os.Exit(m.Run())
 }
@@ -160,8 +168,8 @@ func ExampleCLI_Run_setRoot() {
 
 func ExampleCLI_Run_replaceRoot() {
 	_reset("/")
-	_run("ssmenv replace --path / bar=v1")
-	_run("ssmenv replace --path / bar=v1")
+	_run("ssmenv replace --path / --force bar=v1")
+	_run("ssmenv replace --path / --force bar=v1")
 	// Unordered output:
 	// PUT /bar=v1
 	// DELETE /foo
@@ -213,20 +221,116 @@ func ExampleCLI_Run_execSecure() {
 	// password=pwd
 }
 
+func ExampleCLI_Run_execRedactOutput() {
+	_reset("/empty")
+	_run("ssmenv set --path /empty password@=passw0rd")
+	_run(`ssmenv exec --path /empty --redact-output sh -c 'echo "password: $password"; printf %s "$password" | base64'`)
+	_run(`ssmenv exec --path /empty --redact-output --redact-encodings hex ` +
+		`sh -c 'printf %s "$password" | od -An -tx1 | tr -d " "'`)
+	// Output:
+	// PUT /empty/password@=****************
+	// password: ****
+	// ****
+	// ****
+}
+
+func ExampleCLI_Run_execRedactOutputShortValues() {
+	_reset("/empty")
+	_runIn("ssmenv set --path /empty", "flag@=1\njson@=\"{\\n1\\n}\"\npassword@=passw0rd")
+	_run(`ssmenv exec --path /empty --redact-output sh -c 'echo 1 { }; echo "$json" | tr "\n" " "; echo $password'`)
+	// Unordered output:
+	// PUT /empty/flag@=****************
+	// PUT /empty/json@=****************
+	// PUT /empty/password@=****************
+	// 1 { }
+	// { 1 } ****
+}
+
+func TestCLI_Run_execRedactedExitStatus(t *testing.T) {
+	out, err := _runOut("ssmenv exec --path /empty --redact-output sh -c 'exit 3'")
+	if e, ok := err.(*exec.ExitError); !ok || e.Sys().(syscall.WaitStatus).ExitStatus() != 3 {
+		t.Errorf("got: %T (%v), want: exit status 3", err, err)
+	}
+	if out != "" {
+		t.Errorf("the error must not be printed: %v", out)
+	}
+}
+
+func TestCLI_Run_execErrUnknownEncoding(t *testing.T) {
+	testError(t, "ssmenv exec --redact-output --redact-encodings rot13 env", lib.ErrUnknownEncoding{Encoding: "rot13"})
+}
+
 func ExampleCLI_Run_getSecure() {
 	_reset("/secure")
 	_run("ssmenv get --path /secure")
+	_run("ssmenv get --path /secure --reveal")
+	_run("ssmenv get --path /secure --no-decrypt")
+	_run("ssmenv get --path /secure --reveal password")
+	_run("ssmenv get --path /secure --format json")
 	// Output:
+	// password@=****************
 	// password@=pwd
+	// password@=****************
+	// pwd
+	// {
+	//   "password": "****************"
+	// }
+}
+
+func TestCLI_Run_getSecureMasked(t *testing.T) {
+	_reset("/secure")
+	want := []string{"/secure/password"}
+	for _, command := range []string{
+		"ssmenv get --path /secure --export",
+		"ssmenv get --path /secure --export --no-decrypt",
+		"ssmenv get --path /secure password",
+	} {
+		out, err := _runOut(command)
+		if e, ok := err.(lib.ErrMaskedValues); !ok || !reflect.DeepEqual(e.Names, want) {
+			t.Errorf("%v\n got: %v\nwant: %v", command, err, want)
+		}
+		if strings.Contains(out, "****") {
+			t.Errorf("%v\nmasked values must not be printed: %v", command, out)
+		}
+	}
 }
 
 func ExampleCLI_Run_getSecureExport() {
 	_reset("/secure")
-	_run("ssmenv get --path /secure --export")
+	_run("ssmenv get --path /secure --export --reveal")
 	// Output:
 	// export password=pwd
 }
 
+func TestCLI_Run_importErrMaskedInput(t *testing.T) {
+	_reset("/secure")
+	out, err := _runOut("ssmenv get --path /secure")
+	if err != nil {
+		t.Fatalf("err must be nil: %v", err)
+	}
+	file := _tempFile("envfile", out)
+	defer os.RemoveAll(filepath.Dir(file)) // nolint: errcheck
+
+	want := []string{"password"}
+	for _, command := range []string{
+		"ssmenv import --path /secure " + file,
+		"ssmenv set --path /secure password@=****************",
+		"ssmenv diff file:" + file + " /secure",
+	} {
+		_, err := _runOut(command)
+		if e, ok := err.(lib.ErrMaskedInput); !ok || !reflect.DeepEqual(e.Names, want) {
+			t.Errorf("%v\n got: %v\nwant: %v", command, err, want)
+		}
+	}
+	if *_get("/secure")["/secure/password"].Value != "pwd" {
+		t.Errorf("SecureString values must not be overwritten with the mask")
+	}
+}
+
+func TestCLI_Run_getErrRevealWithNoDecrypt(t *testing.T) {
+	testError(t, "ssmenv get --reveal --no-decrypt", ErrRevealWithNoDecrypt)
+}
+
 func ExampleCLI_Run_setSecure() {
 	_reset("/empty")
 	_run("ssmenv set --path /empty password@=pwd")
@@ -236,6 +340,283 @@ func ExampleCLI_Run_setSecure() {
 	// UNCHANGED /empty/password@=****************
 }
 
+func ExampleCLI_Run_setKMSKey() {
+	_reset("/empty")
+	_run("ssmenv set --path /empty password@=pwd")
+	_run("ssmenv set --path /empty --kms-key-id alias/ssmenv-test password@=pwd")
+	_run("ssmenv set --path /empty password@alias/ssmenv-test=pwd")
+	// Output:
+	// PUT /empty/password@=****************
+	// PUT /empty/password@alias/ssmenv-test=**************** (KMS key alias/aws/ssm -> alias/ssmenv-test)
+	// UNCHANGED /empty/password@alias/ssmenv-test=****************
+}
+
+func ExampleCLI_Run_setKMSKeyConfig() {
+	_reset("/empty")
+	file := _tempFile("ssmenv.yml", "kms_key_ids:\n  /: alias/aws/ssm\n  /empty/db: alias/ssmenv-test\n")
+	defer os.RemoveAll(filepath.Dir(file)) // nolint: errcheck
+	_run("ssmenv set --config " + file + " --path /empty password@=pwd db/password@=pwd")
+	// Unordered output:
+	// PUT /empty/db/password@alias/ssmenv-test=****************
+	// PUT /empty/password@alias/aws/ssm=****************
+}
+
+func ExampleCLI_Run_setAnnotations() {
+	_reset("/empty")
+	_runIn("ssmenv set --path /empty", "# @description The name of the database\n# @tag owner=team-a\nDBNAME=db")
+	_runIn("ssmenv set --path /empty", "# @description The name of the database\n# @tag owner=team-a\nDBNAME=db")
+	_runIn("ssmenv set --path /empty", "# @tag owner=team-b\nDBNAME=db")
+	// Output:
+	// PUT /empty/DBNAME=db
+	// UNCHANGED /empty/DBNAME=db
+	// PUT /empty/DBNAME=db (metadata)
+}
+
+func TestCLI_Run_replaceAnnotations(t *testing.T) {
+	_reset("/empty")
+	_runIn("ssmenv set --path /empty", "# @tag owner=team-a\n# @tag env=test\nDBNAME=db")
+	_runIn("ssmenv replace --path /empty", "# @description The name\n# @tag owner=team-b\nDBNAME=db")
+
+	output, err := svc.ListTagsForResource(&ssm.ListTagsForResourceInput{
+		ResourceType: aws.String(ssm.ResourceTypeForTaggingParameter),
+		ResourceId:   aws.String("/empty/DBNAME"),
+	})
+	if err != nil {
+		t.Fatalf("err must be nil: %v", err)
+	}
+	if len(output.TagList) != 1 || *output.TagList[0].Key != "owner" || *output.TagList[0].Value != "team-b" {
+		t.Errorf("got: %v", output.TagList)
+	}
+
+	meta, err := svc.DescribeParameters(&ssm.DescribeParametersInput{
+		ParameterFilters: []*ssm.ParameterStringFilter{{
+			Key:    aws.String("Name"),
+			Option: aws.String("Equals"),
+			Values: []*string{aws.String("/empty/DBNAME")},
+		}},
+	})
+	if err != nil {
+		t.Fatalf("err must be nil: %v", err)
+	}
+	if len(meta.Parameters) != 1 || aws.StringValue(meta.Parameters[0].Description) != "The name" {
+		t.Errorf("got: %v", meta.Parameters)
+	}
+}
+
+func TestCLI_Run_expiring(t *testing.T) {
+	_reset("/empty")
+	_run("ssmenv set --path /empty --expires-in 7d --notify-before 2d --notify-no-change 3d password@=pwd")
+	_run("ssmenv set --path /empty foo=v1")
+
+	out, err := _runOut("ssmenv expiring --path /empty --within 14d")
+	if err != nil {
+		t.Fatalf("err must be nil: %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(out), "\n")
+	if len(lines) != 2 || !strings.HasSuffix(lines[1], "SecureString  /empty/password") {
+		t.Errorf("got: %v", out)
+	}
+
+	out, err = _runOut("ssmenv expiring --path /empty --within 1d")
+	if err != nil {
+		t.Fatalf("err must be nil: %v", err)
+	}
+	if want := "EXPIRES  TYPE  NAME\n"; out != want {
+		t.Errorf("\ngot:\n%v\nwant:\n%v", out, want)
+	}
+}
+
+func ExampleCLI_Run_setExpiresInUnchanged() {
+	_reset("/empty")
+	_run("ssmenv set --path /empty foo=v1")
+	_run("ssmenv set --path /empty --expires-in 7d foo=v1")
+	_run("ssmenv set --path /empty --expires-in 14d foo=v1")
+	// Output:
+	// PUT /empty/foo=v1
+	// PUT /empty/foo=v1 (metadata)
+	// UNCHANGED /empty/foo=v1
+}
+
+func TestCLI_Run_setTierStandard(t *testing.T) {
+	_reset("/empty")
+	testError(t, "ssmenv set --path /empty --tier Standard --expires-in 7d foo=v1",
+		lib.ErrPoliciesInStandard{Name: "/empty/foo"})
+
+	_run("ssmenv set --path /empty --tier Advanced foo=v1")
+	testError(t, "ssmenv set --path /empty --tier Standard foo=v2", lib.ErrTierDowngrade{Name: "/empty/foo"})
+	if value := *_get("/empty")["/empty/foo"].Value; value != "v1" {
+		t.Errorf("got: %v", value)
+	}
+}
+
+func TestCLI_Run_setTierAuto(t *testing.T) {
+	_reset("/empty")
+	value := strings.Repeat("x", 5000)
+	testError(t, "ssmenv set --path /empty big="+value, lib.ErrValueTooLarge{
+		Name: "/empty/big",
+		Size: 5000,
+		Tier: "Standard",
+		Max:  4096,
+	})
+
+	_run("ssmenv set --path /empty --tier auto big=" + value + " small=v1")
+	out, err := _runOut("ssmenv get --path /empty --format json --metadata")
+	if err != nil {
+		t.Fatalf("err must be nil: %v", err)
+	}
+	var params map[string]struct {
+		Type, Tier string
+		Size       int
+	}
+	if err := json.Unmarshal([]byte(out), &params); err != nil {
+		t.Fatalf("err must be nil: %v", err)
+	}
+	if p := params["big"]; p.Type != "String" || p.Tier != "Advanced" || p.Size != 5000 {
+		t.Errorf("got: %+v", p)
+	}
+	if p := params["small"]; p.Tier != "Standard" || p.Size != 2 {
+		t.Errorf("got: %+v", p)
+	}
+}
+
+func TestCLI_Run_setAllowedPattern(t *testing.T) {
+	_reset("/empty")
+	testError(
+		t,
+		"ssmenv set --path /empty '# @pattern ^[0-9]+$' port=http",
+		lib.ErrPatternMismatch{Name: "/empty/port", Pattern: "^[0-9]+$"},
+	)
+	if out, err := _runOut("ssmenv set --path /empty '# @pattern ^[0-9]+$' port=80"); err != nil {
+		t.Errorf("err must be nil: %v, out: %v", err, out)
+	}
+	if _, err := _runOut("ssmenv set --path /empty port=http"); err == nil {
+		t.Error("err must not be nil")
+	}
+}
+
+func TestCLI_Run_validate(t *testing.T) {
+	_reset("/rpl")
+	schema := _tempFile("schema.yml", `parameters:
+  foo:
+    required: true
+    pattern: ^v[0-9]$
+  bar:
+    enum: [v1]
+  baz/*:
+    max_length: 2
+  qux:
+    required: true
+`)
+	defer os.RemoveAll(filepath.Dir(schema)) // nolint: errcheck
+
+	_, err := _runOut("ssmenv validate --path /rpl --recursive --schema " + schema)
+	want := []string{"bar is not one of v1", "qux is required"}
+	if e, ok := err.(lib.ErrSchemaViolations); !ok || !reflect.DeepEqual(e.Violations, want) {
+		t.Errorf("\n got: %v\nwant: %v", err, want)
+	}
+
+	_, err = _runOut("ssmenv set --path /rpl --schema " + schema + " foo=x baz/foo=v10")
+	want = []string{"baz/foo is longer than 2", "foo does not match ^v[0-9]$"}
+	if e, ok := err.(lib.ErrSchemaViolations); !ok || !reflect.DeepEqual(e.Violations, want) {
+		t.Errorf("\n got: %v\nwant: %v", err, want)
+	}
+
+	_, err = _runOut("ssmenv set --schema " + schema + " /rpl/foo=x /rpl/baz/foo=v10")
+	want = []string{"/rpl/baz/foo is longer than 2", "/rpl/foo does not match ^v[0-9]$"}
+	if e, ok := err.(lib.ErrSchemaViolations); !ok || !reflect.DeepEqual(e.Violations, want) {
+		t.Errorf("\n got: %v\nwant: %v", err, want)
+	}
+
+	if out, err := _runOut("ssmenv set --path /rpl --schema " + schema + " foo=v5"); err != nil {
+		t.Errorf("err must be nil: %v, out: %v", err, out)
+	}
+}
+
+func ExampleCLI_Run_getWithFilters() {
+	_reset("/empty")
+	_run("ssmenv set --path /empty '# @tag service=api' foo=v1")
+	_run("ssmenv set --path /empty bar=v2 password@=pwd")
+	_run("ssmenv label --path /empty foo stable")
+	_run("ssmenv get --path /empty --tag service=api")
+	_run("ssmenv get --path /empty --type SecureString")
+	_run("ssmenv get --path /empty --name-contains ar")
+	_run("ssmenv get --path /empty --name-contains empty")
+	_run("ssmenv get --path /empty --label stable --modified-since 1h")
+	_run("ssmenv exec --path /empty --tag service=api env" + _unsetEnviron())
+	// Output:
+	// foo=v1
+	// password@=****************
+	// bar=v2
+	// foo=v1
+	// foo=v1
+}
+
+func TestCLI_Run_getWithFiltersError(t *testing.T) {
+	testError(t, "ssmenv get --path /empty --tag service", lib.ErrInvalidTag{Tag: "service"})
+	testError(t, "ssmenv get --path /empty --label stable /empty/foo", ErrFilterWithName)
+	testError(t, "ssmenv get --path /empty:1 --label stable", lib.ErrLabelWithSelector)
+}
+
+func TestCLI_Run_ls(t *testing.T) {
+	_reset("/rpl")
+	tests := []struct {
+		command string
+		want    []string
+	}{
+		{
+			"ssmenv ls --path /rpl",
+			[]string{"/rpl", "├── bar ", "├── baz/", "│   ├── bar ", "│   └── foo ", "└── foo "},
+		},
+		{
+			"ssmenv ls --path /rpl --depth 1",
+			[]string{"/rpl", "├── bar ", "├── baz/", "└── foo "},
+		},
+		{
+			"ssmenv ls --path /rpl --name-contains baz",
+			[]string{"/rpl", "└── baz/", "    ├── bar ", "    └── foo "},
+		},
+	}
+	for _, tt := range tests {
+		out, err := _runOut(tt.command)
+		if err != nil {
+			t.Fatalf("err must be nil: %v", err)
+		}
+		lines := strings.Split(strings.TrimSuffix(out, "\n"), "\n")
+		if len(lines) != len(tt.want)+1 || !strings.HasPrefix(lines[0], "NAME ") {
+			t.Errorf("%v\ngot:\n%v", tt.command, out)
+			continue
+		}
+		for i, prefix := range tt.want {
+			if !strings.HasPrefix(lines[i+1], prefix) {
+				t.Errorf("%v\ngot:\n%v\nwant prefix: %v", tt.command, lines[i+1], prefix)
+			}
+		}
+	}
+
+	testError(t, "ssmenv ls --path /rpl --depth -1", ErrNegativeDepth)
+}
+
+func ExampleCLI_Run_search() {
+	_reset("/rpl")
+	_reset("/secure")
+	_run("ssmenv search --path /rpl foo")
+	_run("ssmenv search --path /rpl --regex 'ba[rz]$'")
+	_run("ssmenv search --path /rpl --values v3")
+	_run("ssmenv search --path /secure --values wd")
+	// Output:
+	// /rpl/baz/foo
+	// /rpl/foo
+	// /rpl/bar
+	// /rpl/baz/bar
+	// /rpl/baz/foo: **
+	// /secure/password: ***
+}
+
+func TestCLI_Run_searchError(t *testing.T) {
+	testError(t, "ssmenv search --path /rpl", ErrRequirePattern)
+	testError(t, "ssmenv search --path /rpl foo bar", ErrTooManyArguments)
+}
+
 func ExampleCLI_Run_execSymbol() {
 	_reset("/symbol")
 	_run("ssmenv exec --path /symbol env" + _unsetEnviron())
@@ -454,6 +835,626 @@ foo=v1
 	// /empty/bar v2
 }
 
+func ExampleCLI_Run_importDotenv() {
+	_reset("/empty")
+	stdin := `
+# comment
+export FOO=v1
+BAR="x\ty" # trailing comment
+DB_PASS='pwd'
+`
+	_runIn("ssmenv import --path /empty --secure-suffixes _PASS", stdin)
+	for _, p := range _get("/empty") {
+		fmt.Println(*p.Name, *p.Type, *p.Value)
+	}
+	// Unordered output:
+	// PUT /empty/FOO=v1
+	// PUT /empty/BAR="x\ty"
+	// PUT /empty/DB_PASS@=****************
+	// /empty/FOO String v1
+	// /empty/BAR String x	y
+	// /empty/DB_PASS SecureString pwd
+}
+
+func ExampleCLI_Run_importJSON() {
+	_reset("/empty")
+	file := _tempFile("params.json", `{"foo": "v1", "bar": {"baz": 2, "password": "pwd"}}`)
+	defer os.RemoveAll(filepath.Dir(file)) // nolint: errcheck
+	_run("ssmenv import --path /empty --secure-keys password " + file)
+	// Unordered output:
+	// PUT /empty/foo=v1
+	// PUT /empty/bar/baz=2
+	// PUT /empty/bar/password@=****************
+}
+
+func ExampleCLI_Run_importJSONWithCipher() {
+	_reset("/empty")
+	file := _tempFile("params.json", `{"Cipher": "aes"}`)
+	defer os.RemoveAll(filepath.Dir(file)) // nolint: errcheck
+	_run("ssmenv import --path /empty " + file)
+	// Output:
+	// PUT /empty/Cipher=aes
+}
+
+func ExampleCLI_Run_importJSONArray() {
+	_reset("/empty")
+	file := _tempFile("params.json", `{"hosts": ["a.local", "b.local"]}`)
+	defer os.RemoveAll(filepath.Dir(file)) // nolint: errcheck
+	_run("ssmenv import --path /empty " + file)
+	fmt.Println(*_get("/empty")["/empty/hosts"].Type)
+	// Output:
+	// PUT /empty/hosts[]=a.local,b.local
+	// StringList
+}
+
+func ExampleCLI_Run_importPropertiesReplace() {
+	_reset("/rpl")
+	file := _tempFile("params.properties", "foo = n\\\n    1\nqux: n2\n")
+	defer os.RemoveAll(filepath.Dir(file)) // nolint: errcheck
+	_run("ssmenv import --path /rpl --replace " + file)
+	// Unordered output:
+	// PUT /rpl/foo=n1
+	// PUT /rpl/qux=n2
+	// DELETE /rpl/bar
+}
+
+func ExampleCLI_Run_backupAndRestore() {
+	_reset("/rpl")
+	_reset("/empty")
+	file := _tempFile("backup.json", "")
+	defer os.RemoveAll(filepath.Dir(file)) // nolint: errcheck
+	_run("ssmenv backup --path /rpl --recursive -o " + file)
+	_run("ssmenv restore --path-rewrite /rpl=/empty " + file)
+	for _, p := range _get("/empty") {
+		fmt.Println(*p.Name, *p.Value)
+	}
+	// Unordered output:
+	// PUT /empty/foo=v1
+	// PUT /empty/bar=v2
+	// PUT /empty/baz/foo=v3
+	// PUT /empty/baz/bar=v4
+	// /empty/foo v1
+	// /empty/bar v2
+	// /empty/baz/foo v3
+	// /empty/baz/bar v4
+}
+
+func TestCLI_Run_backupEncrypted(t *testing.T) {
+	_reset("/secure")
+	dir := filepath.Dir(_tempFile("passphrase", "passw0rd\n"))
+	defer os.RemoveAll(dir) // nolint: errcheck
+	passphraseFile := filepath.Join(dir, "passphrase")
+	backupFile := filepath.Join(dir, "backup.json")
+
+	_run("ssmenv backup --path /secure --encrypt --passphrase-file " + passphraseFile + " -o " + backupFile)
+	data, err := ioutil.ReadFile(backupFile)
+	if err != nil {
+		t.Fatalf("err must be nil: %v", err)
+	}
+	if !lib.IsEncrypted(data) || bytes.Contains(data, []byte("pwd")) {
+		t.Fatalf("backup must be encrypted:\n%s", data)
+	}
+
+	wrongFile := _tempFile("wrong", "wrong")
+	defer os.RemoveAll(filepath.Dir(wrongFile)) // nolint: errcheck
+	testError(t, "ssmenv restore --passphrase-file "+wrongFile+" "+backupFile, lib.ErrDecrypt)
+
+	out, err := _runOut("ssmenv restore --passphrase-file " + passphraseFile + " " + backupFile)
+	if err != nil {
+		t.Fatalf("err must be nil: %v", err)
+	}
+	if want := "PUT /secure/password@=****************\n"; out != want {
+		t.Errorf("\ngot:\n%v\nwant:\n%v", out, want)
+	}
+}
+
+func TestCLI_Run_restoreErrInvalidEncryptedDocument(t *testing.T) {
+	_reset("/secure")
+	dir := filepath.Dir(_tempFile("passphrase", "passw0rd\n"))
+	defer os.RemoveAll(dir) // nolint: errcheck
+	passphraseFile := filepath.Join(dir, "passphrase")
+	backupFile := filepath.Join(dir, "backup.json")
+
+	_run("ssmenv backup --path /secure --encrypt --passphrase-file " + passphraseFile + " -o " + backupFile)
+	data, err := ioutil.ReadFile(backupFile)
+	if err != nil {
+		t.Fatalf("err must be nil: %v", err)
+	}
+
+	for _, params := range []map[string]interface{}{
+		{"N": 1 << 30},
+		{"N": 1 << 20, "R": 32},
+		{"P": 16},
+	} {
+		var doc map[string]interface{}
+		if err = json.Unmarshal(data, &doc); err != nil {
+			t.Fatalf("err must be nil: %v", err)
+		}
+		for key, value := range params {
+			doc[key] = value
+		}
+		var tampered []byte
+		if tampered, err = json.Marshal(doc); err != nil {
+			t.Fatalf("err must be nil: %v", err)
+		}
+		if err = ioutil.WriteFile(backupFile, tampered, 0600); err != nil {
+			t.Fatalf("err must be nil: %v", err)
+		}
+		testError(t, "ssmenv restore --passphrase-file "+passphraseFile+" "+backupFile, lib.ErrInvalidEncryptedDocument)
+	}
+}
+
+func TestCLI_Run_getEncrypted(t *testing.T) {
+	_reset("/secure")
+	_reset("/empty")
+	passphraseFile := _tempFile("passphrase", "passw0rd\n")
+	defer os.RemoveAll(filepath.Dir(passphraseFile)) // nolint: errcheck
+
+	testError(t, "ssmenv get --path /secure --encrypt --passphrase-file "+passphraseFile, ErrEncryptWithoutReveal)
+
+	out, err := _runOut("ssmenv get --path /secure --reveal --encrypt --passphrase-file " + passphraseFile)
+	if err != nil {
+		t.Fatalf("err must be nil: %v", err)
+	}
+	if !lib.IsEncrypted([]byte(out)) || strings.Contains(out, "pwd") {
+		t.Fatalf("output must be encrypted:\n%s", out)
+	}
+
+	file := _tempFile("secure.env", out)
+	defer os.RemoveAll(filepath.Dir(file)) // nolint: errcheck
+	_run("ssmenv import --path /empty --passphrase-file " + passphraseFile + " " + file)
+	if p := _get("/empty")["/empty/password"]; *p.Type != "SecureString" || *p.Value != "pwd" {
+		t.Errorf("got: %v", p)
+	}
+}
+
+func ExampleCLI_Run_diff() {
+	_reset("/exc")
+	_run("ssmenv diff --recursive /exc/AppA /exc/AppB")
+	_run("ssmenv diff --recursive /exc/Common /exc/Common")
+	// Output:
+	// - KEY=v2
+	// + KEY=v3
+}
+
+func ExampleCLI_Run_diffFile() {
+	_reset("/rpl")
+	file := _tempFile("envfile", "foo=v1\nbar@=v2\nqux=v5\n")
+	defer os.RemoveAll(filepath.Dir(file)) // nolint: errcheck
+	_run("ssmenv diff file:" + file + " /rpl")
+	_run("ssmenv diff --show-values file:" + file + " /rpl")
+	// Output:
+	// - bar@=****************
+	// + bar=v2
+	// - qux=v5
+	// - bar@=v2
+	// + bar=v2
+	// - qux=v5
+}
+
+func TestCLI_Run_diffJSON(t *testing.T) {
+	_reset("/rpl")
+	out, err := _runOut("ssmenv diff --format json /rpl/baz /rpl")
+	if err != nil {
+		t.Fatalf("err must be nil: %v", err)
+	}
+	var result struct{ Added, Removed, Changed []struct{ Name string } }
+	if err := json.Unmarshal([]byte(out), &result); err != nil {
+		t.Fatalf("err must be nil: %v", err)
+	}
+	if len(result.Added) != 0 || len(result.Removed) != 0 || len(result.Changed) != 2 {
+		t.Errorf("got: %v", out)
+	}
+}
+
+func TestCLI_Run_replaceDryRun(t *testing.T) {
+	_reset("/rpl")
+
+	out, err := _runOut("ssmenv replace --path /rpl --dry-run foo=v1 qux@=n1")
+	if err != lib.ErrChangesPending {
+		t.Fatalf("got: %v, want: %v", err, lib.ErrChangesPending)
+	}
+	want := `UNCHANGED /rpl/foo=v1
+PUT /rpl/qux@=****************
+DELETE /rpl/bar
+`
+	if out != want {
+		t.Errorf("\ngot:\n%v\nwant:\n%v", out, want)
+	}
+
+	if len(_get("/rpl")) != len(initialParams["/rpl"]) {
+		t.Errorf("dry-run must not write parameters")
+	}
+}
+
+func TestCLI_Run_setDryRun(t *testing.T) {
+	_reset("/rpl")
+
+	out, err := _runOut("ssmenv set --path /rpl --dry-run foo=v1 bar@=v2")
+	if err != lib.ErrChangesPending {
+		t.Fatalf("got: %v, want: %v", err, lib.ErrChangesPending)
+	}
+	want := `UNCHANGED /rpl/foo=v1
+PUT /rpl/bar@=**************** (String -> SecureString)
+`
+	if out != want {
+		t.Errorf("\ngot:\n%v\nwant:\n%v", out, want)
+	}
+
+	out, err = _runOut("ssmenv set --path /rpl --dry-run foo=v1")
+	if err != nil {
+		t.Fatalf("err must be nil: %v", err)
+	}
+	if want := "UNCHANGED /rpl/foo=v1\n"; out != want {
+		t.Errorf("\ngot:\n%v\nwant:\n%v", out, want)
+	}
+
+	if *_get("/rpl")["/rpl/bar"].Type != "String" {
+		t.Errorf("dry-run must not write parameters")
+	}
+}
+
+func TestCLI_Run_replaceDeleteGracePeriod(t *testing.T) {
+	_reset("/rpl")
+
+	out, err := _runOut("ssmenv replace --path /rpl --delete-grace-period 1s foo=n1 qux=n2")
+	if err != nil {
+		t.Fatalf("err must be nil: %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(out), "\n")
+	if len(lines) != 4 {
+		t.Fatalf("got:\n%v", out)
+	}
+	if !_unorderdMatch(strings.Join(lines[:2], "\n"), "PUT /rpl/foo=n1\nPUT /rpl/qux=n2") {
+		t.Errorf("new values must be put first:\n%v", out)
+	}
+	if lines[2] != "WAIT 1s" || lines[3] != "DELETE /rpl/bar" {
+		t.Errorf("obsolete parameters must be deleted last:\n%v", out)
+	}
+}
+
+func TestCLI_Run_setRollback(t *testing.T) {
+	_reset("/rpl")
+
+	// A too long name passes the validation of ssmenv but is rejected by the API.
+	tooLong := strings.Repeat("x", 2048)
+	_, err := _runOut("ssmenv set --path /rpl foo=n1 qux=n2 " + tooLong + "=n3")
+	e, ok := err.(lib.ErrPartialUpdate)
+	if !ok {
+		t.Fatalf("got: %T (%v), want: ErrPartialUpdate", err, err)
+	}
+	if len(e.NotRolledBack) != 0 {
+		t.Errorf("got: %v, want: all changes are rolled back", e)
+	}
+
+	params := _get("/rpl")
+	if *params["/rpl/foo"].Value != "v1" {
+		t.Errorf("got: %v, want: v1", *params["/rpl/foo"].Value)
+	}
+	if _, ok := params["/rpl/qux"]; ok {
+		t.Errorf("/rpl/qux must be deleted")
+	}
+}
+
+func ExampleCLI_Run_delete() {
+	_reset("/rpl")
+	_run("ssmenv delete --path /rpl foo baz/foo qux")
+	for _, p := range _get("/rpl") {
+		fmt.Println(*p.Name, *p.Value)
+	}
+	// Unordered output:
+	// DELETE /rpl/foo
+	// DELETE /rpl/baz/foo
+	// /rpl/bar v2
+	// /rpl/baz/bar v4
+}
+
+func ExampleCLI_Run_deleteWithMatch() {
+	_reset("/gt10")
+	_run("ssmenv delete --path /gt10 --match /gt10/p0[0-4]")
+	out, _ := _runOut("ssmenv delete --path /gt10 --dry-run --match /gt10/p1*")
+	fmt.Print(out)
+	// Unordered output:
+	// DELETE /gt10/p00
+	// DELETE /gt10/p01
+	// DELETE /gt10/p02
+	// DELETE /gt10/p03
+	// DELETE /gt10/p04
+	// DELETE /gt10/p10
+}
+
+func ExampleCLI_Run_copy() {
+	_reset("/rpl")
+	_reset("/empty")
+	_run("ssmenv copy --recursive /rpl/baz /empty")
+	_run("ssmenv copy /rpl/foo /empty/qux")
+	for _, p := range _get("/empty") {
+		fmt.Println(*p.Name, *p.Value)
+	}
+	// Unordered output:
+	// PUT /empty/foo=v3
+	// PUT /empty/bar=v4
+	// PUT /empty/qux=v1
+	// /empty/foo v3
+	// /empty/bar v4
+	// /empty/qux v1
+}
+
+func ExampleCLI_Run_copySkipExisting() {
+	_reset("/rpl")
+	_reset("/empty")
+	_run("ssmenv copy /rpl/foo /empty/foo")
+	_run("ssmenv copy --skip-existing /rpl /empty")
+	for _, p := range _get("/empty") {
+		fmt.Println(*p.Name, *p.Value)
+	}
+	// Unordered output:
+	// PUT /empty/foo=v1
+	// SKIP /empty/foo
+	// PUT /empty/bar=v2
+	// /empty/foo v1
+	// /empty/bar v2
+}
+
+func ExampleCLI_Run_move() {
+	_reset("/rpl")
+	_reset("/empty")
+	_run("ssmenv move --recursive /rpl/baz /empty")
+	for _, p := range _get("/rpl") {
+		fmt.Println(*p.Name, *p.Value)
+	}
+	for _, p := range _get("/empty") {
+		fmt.Println(*p.Name, *p.Value)
+	}
+	// Unordered output:
+	// PUT /empty/foo=v3
+	// PUT /empty/bar=v4
+	// DELETE /rpl/baz/foo
+	// DELETE /rpl/baz/bar
+	// /rpl/foo v1
+	// /rpl/bar v2
+	// /empty/foo v3
+	// /empty/bar v4
+}
+
+func ExampleCLI_Run_copyKMSKey() {
+	_reset("/empty")
+	_run("ssmenv set --path /empty password@alias/ssmenv-test=pwd")
+	_run("ssmenv copy /empty/password /empty/copied")
+	// Output:
+	// PUT /empty/password@alias/ssmenv-test=****************
+	// PUT /empty/copied@alias/ssmenv-test=****************
+}
+
+// TestCLI_Run_copyKMSKeyOtherRegion copies into $SSMENV_TEST_DST_REGION, where the key of src does not exist.
+func TestCLI_Run_copyKMSKeyOtherRegion(t *testing.T) {
+	dstRegion := os.Getenv("SSMENV_TEST_DST_REGION")
+	if dstRegion == "" {
+		t.Skip("$SSMENV_TEST_DST_REGION is not set")
+	}
+
+	_reset("/empty")
+	_run("ssmenv set --path /empty password@alias/ssmenv-test=pwd")
+	defer _run("ssmenv delete --region " + dstRegion + " /empty/password")
+
+	out, err := _runOut("ssmenv copy --dst-region " + dstRegion + " /empty/password /empty/password")
+	if err != nil {
+		t.Fatalf("err must be nil: %v", err)
+	}
+	if want := "PUT /empty/password@=****************\n"; out != want {
+		t.Errorf("\n got: %v\nwant: %v", out, want)
+	}
+}
+
+func TestCLI_Run_copyErrParameterExists(t *testing.T) {
+	_reset("/rpl")
+	testError(t, "ssmenv copy /rpl/foo /rpl/bar", lib.ErrParameterExists{Name: "/rpl/bar"})
+	if *_get("/rpl")["/rpl/bar"].Value != "v2" {
+		t.Errorf("existing parameters must not be overwritten")
+	}
+
+	if _, err := _runOut("ssmenv copy --overwrite /rpl/foo /rpl/bar"); err != nil {
+		t.Fatalf("err must be nil: %v", err)
+	}
+	if *_get("/rpl")["/rpl/bar"].Value != "v1" {
+		t.Errorf("existing parameters must be overwritten with --overwrite")
+	}
+}
+
+func ExampleCLI_Run_sync() {
+	_reset("/rpl")
+	_reset("/empty")
+	_run("ssmenv sync --recursive /rpl /empty")
+	// Unordered output:
+	// PUT /empty/bar=v2
+	// PUT /empty/foo=v1
+	// PUT /empty/baz/bar=v4
+	// PUT /empty/baz/foo=v3
+}
+
+func TestCLI_Run_syncDeleteAndExclude(t *testing.T) {
+	_reset("/rpl")
+	_reset("/empty")
+	_run("ssmenv set /empty/foo=n1 /empty/qux=n2 /empty/baz/qux=n3")
+
+	out, err := _runOut("ssmenv sync --recursive --delete --exclude 'baz/*' /rpl /empty")
+	if err != nil {
+		t.Fatalf("err must be nil: %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(out), "\n")
+	if len(lines) != 3 || !_unorderdMatch(strings.Join(lines[:2], "\n"), "PUT /empty/bar=v2\nPUT /empty/foo=v1") ||
+		lines[2] != "DELETE /empty/qux" {
+		t.Errorf("got:\n%v", out)
+	}
+
+	params := _get("/empty")
+	if len(params) != 3 || *params["/empty/foo"].Value != "v1" || *params["/empty/baz/qux"].Value != "n3" {
+		t.Errorf("got: %v", params)
+	}
+
+	// Sync is idempotent.
+	out, err = _runOut("ssmenv sync --recursive --delete --exclude 'baz/*' --dry-run /rpl /empty")
+	if err != nil {
+		t.Fatalf("err must be nil: %v", err)
+	}
+	if want := "UNCHANGED /empty/bar=v2\nUNCHANGED /empty/foo=v1\n"; out != want {
+		t.Errorf("\ngot:\n%v\nwant:\n%v", out, want)
+	}
+}
+
+func TestCLI_Run_history(t *testing.T) {
+	_reset("/empty")
+	_run("ssmenv set --path /empty foo=v1")
+	_run("ssmenv set --path /empty foo@=v2")
+
+	out, err := _runOut("ssmenv history --path /empty --format json foo")
+	if err != nil {
+		t.Fatalf("err must be nil: %v", err)
+	}
+	var history []struct {
+		Version     int64
+		Type, Value string
+	}
+	if err := json.Unmarshal([]byte(out), &history); err != nil {
+		t.Fatalf("err must be nil: %v", err)
+	}
+	if len(history) != 2 ||
+		history[0].Version != 1 || history[0].Type != "String" || history[0].Value != "v1" ||
+		history[1].Version != 2 || history[1].Type != "SecureString" || history[1].Value != "****************" {
+		t.Errorf("got: %v", out)
+	}
+
+	out, err = _runOut("ssmenv history --show-values /empty/foo")
+	if err != nil {
+		t.Fatalf("err must be nil: %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(out), "\n")
+	if len(lines) != 3 || !strings.HasPrefix(lines[0], "VERSION") || !strings.HasSuffix(lines[2], " v2") {
+		t.Errorf("got:\n%v", out)
+	}
+}
+
+func ExampleCLI_Run_rollbackVersion() {
+	_reset("/empty")
+	_run("ssmenv set --path /empty foo=v1")
+	_run("ssmenv set --path /empty foo=v2")
+	_run("ssmenv rollback --path /empty --version 1 foo")
+	fmt.Println(*_get("/empty")["/empty/foo"].Value)
+	// Output:
+	// PUT /empty/foo=v1
+	// PUT /empty/foo=v2
+	// PUT /empty/foo=v1
+	// v1
+}
+
+func TestCLI_Run_rollbackAt(t *testing.T) {
+	_reset("/empty")
+	_run("ssmenv set --path /empty foo=v1")
+	time.Sleep(2 * time.Second)
+	at := time.Now().UTC().Format(time.RFC3339)
+	time.Sleep(2 * time.Second)
+	_run("ssmenv set --path /empty foo=v2 bar=v3")
+
+	out, err := _runOut("ssmenv rollback --path /empty --recursive --yes --at " + at)
+	if err != nil {
+		t.Fatalf("err must be nil: %v", err)
+	}
+	if want := "PUT /empty/foo=v1\nDELETE /empty/bar\n"; out != want {
+		t.Errorf("\ngot:\n%v\nwant:\n%v", out, want)
+	}
+
+	params := _get("/empty")
+	if len(params) != 1 || *params["/empty/foo"].Value != "v1" {
+		t.Errorf("got: %v", params)
+	}
+}
+
+func TestCLI_Run_rollbackErrHistoryNotRetained(t *testing.T) {
+	_reset("/empty")
+	at := time.Now().UTC().Add(-time.Minute).Format(time.RFC3339)
+
+	// SSM retains the last 100 versions, so version 1 is gone after 101 puts.
+	for i := 1; i <= 101; i++ {
+		_, err := svc.PutParameter(&ssm.PutParameterInput{
+			Name:      aws.String("/empty/foo"),
+			Type:      aws.String(ssm.ParameterTypeString),
+			Value:     aws.String(fmt.Sprintf("v%d", i)),
+			Overwrite: aws.Bool(true),
+		})
+		panicIfError(err)
+	}
+
+	want, err := lib.ParseTime(at)
+	panicIfError(err)
+	testError(t, "ssmenv rollback --path /empty --at "+at+" foo", lib.ErrHistoryNotRetained{Name: "/empty/foo", At: want})
+	if *_get("/empty")["/empty/foo"].Value != "v101" {
+		t.Errorf("parameters must not be deleted if their history is not retained")
+	}
+}
+
+func TestCLI_Run_rollbackErrVersionNotFound(t *testing.T) {
+	_reset("/rpl")
+	testError(t, "ssmenv rollback --path /rpl --version 1000 foo", lib.ErrVersionNotFound{Name: "/rpl/foo", Version: 1000})
+}
+
+func ExampleCLI_Run_selector() {
+	_reset("/empty")
+	_run("ssmenv set --path /empty foo=v1 bar=v2")
+	_run("ssmenv set --path /empty foo=n1")
+	_run("ssmenv label --path /empty foo:1 release")
+	_run("ssmenv label --path /empty bar release")
+	_run("ssmenv get --path /empty foo:1")
+	_run("ssmenv get /empty/foo:release")
+	_run("ssmenv get --path /empty:release")
+	_run("ssmenv exec --path /empty:release env" + _unsetEnviron())
+	_run("ssmenv unlabel --path /empty foo release")
+	_run("ssmenv get --path /empty:release")
+	// Unordered output:
+	// PUT /empty/foo=v1
+	// PUT /empty/bar=v2
+	// PUT /empty/foo=n1
+	// LABEL /empty/foo:1 release
+	// LABEL /empty/bar:1 release
+	// v1
+	// v1
+	// foo=v1
+	// bar=v2
+	// foo=v1
+	// bar=v2
+	// UNLABEL /empty/foo:1 release
+	// bar=v2
+}
+
+func TestCLI_Run_unlabelErrInvalidLabels(t *testing.T) {
+	_reset("/rpl")
+	_, err := _runOut("ssmenv unlabel --path /rpl foo release")
+	if e, ok := err.(lib.ErrInvalidLabels); !ok || strings.Join(e.Labels, ",") != "release" {
+		t.Errorf("got: %T (%v), want: ErrInvalidLabels", err, err)
+	}
+}
+
+func ExampleCLI_Run_stringList() {
+	_reset("/empty")
+	_run("ssmenv set --path /empty hosts[]=a.local,b.local")
+	_run("ssmenv get --path /empty")
+	_run("ssmenv replace --path /empty hosts[]=a.local,b.local")
+	_run("ssmenv get --path /empty --format json")
+	_run("ssmenv get --path /empty --format yaml")
+	// Output:
+	// PUT /empty/hosts[]=a.local,b.local
+	// hosts[]=a.local,b.local
+	// UNCHANGED /empty/hosts[]=a.local,b.local
+	// {
+	//   "hosts": [
+	//     "a.local",
+	//     "b.local"
+	//   ]
+	// }
+	// hosts:
+	// - a.local
+	// - b.local
+}
+
 func TestCLI_Run_execErrPathAndPaths(t *testing.T) {
 	testError(t, "ssmenv exec --path /x1 --paths /x2 env", ErrPathAndPaths)
 }
@@ -467,7 +1468,7 @@ func TestCLI_Run_execErrInvalidPath(t *testing.T) {
 }
 
 func TestCLI_Run_getErrInvalidName(t *testing.T) {
-	testError(t, "ssmenv get foo:bar", lib.ErrInvalidName{Name: "foo:bar"})
+	testError(t, "ssmenv get foo:b@r", lib.ErrInvalidName{Name: "foo:b@r"})
 }
 
 func TestCLI_Run_getErrRecursiveWithName(t *testing.T) {
@@ -494,10 +1495,38 @@ func TestCLI_Run_setErrInvalidExpression(t *testing.T) {
 	testError(t, "ssmenv set foo", lib.ErrInvalidExpression{Expr: "foo"})
 }
 
+func TestCLI_Run_setErrInvalidAnnotation(t *testing.T) {
+	testError(t, "ssmenv set '# @owner team-a' foo=v1", lib.ErrInvalidAnnotation{Annotation: "# @owner team-a"})
+}
+
+func TestCLI_Run_setErrDanglingAnnotation(t *testing.T) {
+	testError(t, "ssmenv set foo=v1 '# @tier Advanced'", lib.ErrDanglingAnnotation{Annotation: "# @tier Advanced"})
+}
+
+func TestCLI_Run_setErrInvalidDuration(t *testing.T) {
+	testError(t, "ssmenv set --expires-in 3x foo=v1", lib.ErrInvalidDuration{Value: "3x"})
+}
+
+func TestCLI_Run_setErrNotifyBeforeWithoutExpiration(t *testing.T) {
+	testError(t, "ssmenv set --notify-before 2d foo=v1", lib.ErrNotifyBeforeWithoutExpiration)
+}
+
+func TestCLI_Run_setErrUnknownTier(t *testing.T) {
+	testError(t, "ssmenv set --tier Huge foo=v1", lib.ErrUnknownTier{Tier: "Huge"})
+}
+
+func TestCLI_Run_validateErrRequireSchema(t *testing.T) {
+	testError(t, "ssmenv validate --path /rpl", ErrRequireSchema)
+}
+
 func TestCLI_Run_replaceErrRequirePath(t *testing.T) {
 	testError(t, "ssmenv replace /x=v1", lib.ErrRequirePath)
 }
 
+func TestCLI_Run_getErrMetadataWithText(t *testing.T) {
+	testError(t, "ssmenv get --metadata", ErrMetadataWithText)
+}
+
 func TestCLI_Run_getErrAbsNameWithPath(t *testing.T) {
 	testError(t, "ssmenv get --path /x1 /x2", lib.ErrAbsNameWithPath{Path: "/x1", Name: "/x2"})
 }
@@ -518,6 +1547,119 @@ func TestCLI_Run_replaceErrSlashWithoutRecursive(t *testing.T) {
 	testError(t, "ssmenv replace --path /x foo/bar=v1", lib.ErrSlashWithoutRecursive{Expr: "foo/bar=v1"})
 }
 
+func TestCLI_Run_importErrRequirePath(t *testing.T) {
+	testError(t, "ssmenv import params.env", lib.ErrRequirePath)
+}
+
+func TestCLI_Run_backupErrRequirePassphrase(t *testing.T) {
+	testError(t, "ssmenv backup --encrypt --passphrase-file /dev/null", lib.ErrRequirePassphrase)
+}
+
+func TestCLI_Run_diffErrRequireSrcAndDst(t *testing.T) {
+	testError(t, "ssmenv diff /x", ErrRequireSrcAndDst)
+}
+
+func TestCLI_Run_copyErrRequireSrcAndDst(t *testing.T) {
+	testError(t, "ssmenv copy /x", ErrRequireSrcAndDst)
+}
+
+func TestCLI_Run_copyErrOverwriteAndSkipExisting(t *testing.T) {
+	testError(t, "ssmenv copy --overwrite --skip-existing /x1 /x2", ErrOverwriteAndSkipExisting)
+}
+
+func TestCLI_Run_copyErrParameterNotFound(t *testing.T) {
+	testError(t, "ssmenv copy /x1 /x2", lib.ErrParameterNotFound{Name: "/x1"})
+}
+
+func TestCLI_Run_moveErrSameSrcAndDst(t *testing.T) {
+	_reset("/rpl")
+	testError(t, "ssmenv move /x /x", lib.ErrSameSrcAndDst)
+	testError(t, "ssmenv move --overwrite rpl/foo /rpl/foo", lib.ErrSameSrcAndDst)
+	testError(t, "ssmenv move --recursive --overwrite /rpl /rpl/", lib.ErrSameSrcAndDst)
+	testError(t, "ssmenv copy --recursive --overwrite /rpl/baz/../baz /rpl/baz", lib.ErrSameSrcAndDst)
+	if params := _get("/rpl"); len(params) != 4 {
+		t.Errorf("got: %v", params)
+	}
+}
+
+func TestCLI_Run_syncErrRequireSrcAndDst(t *testing.T) {
+	testError(t, "ssmenv sync /x", ErrRequireSrcAndDst)
+}
+
+func TestCLI_Run_historyErrRequireName(t *testing.T) {
+	testError(t, "ssmenv history", ErrRequireName)
+}
+
+func TestCLI_Run_rollbackErrRequireVersionOrAt(t *testing.T) {
+	testError(t, "ssmenv rollback --version 1 --at 2006-01-02 foo", ErrRequireVersionOrAt)
+}
+
+func TestCLI_Run_rollbackErrRequireName(t *testing.T) {
+	testError(t, "ssmenv rollback --path /x --version 1", ErrRequireName)
+}
+
+func TestCLI_Run_rollbackErrInvalidTime(t *testing.T) {
+	testError(t, "ssmenv rollback --path /x --at yesterday", lib.ErrInvalidTime{Value: "yesterday"})
+}
+
+func TestCLI_Run_labelErrRequireNameAndLabel(t *testing.T) {
+	testError(t, "ssmenv label foo", lib.ErrRequireNameAndLabel)
+}
+
+func TestCLI_Run_labelErrInvalidName(t *testing.T) {
+	testError(t, "ssmenv label foo:release release", lib.ErrInvalidName{Name: "foo:release"})
+}
+
+func TestCLI_Run_getErrFormatWithName(t *testing.T) {
+	testError(t, "ssmenv get --format json foo", ErrFormatWithName)
+}
+
+func TestCLI_Run_getErrExportWithFormat(t *testing.T) {
+	testError(t, "ssmenv get --export --format json", ErrExportWithFormat)
+}
+
+func TestCLI_Run_deleteErrRequirePath(t *testing.T) {
+	testError(t, "ssmenv delete", lib.ErrRequirePath)
+}
+
+func TestCLI_Run_deleteErrRecursiveWithName(t *testing.T) {
+	testError(t, "ssmenv delete --recursive foo", ErrRecursiveWithName)
+}
+
+func TestCLI_Run_importErrUnknownFormat(t *testing.T) {
+	testError(t, "ssmenv import --path /x --format toml", lib.ErrUnknownFormat{Format: "toml"})
+}
+
+func TestCLI_Run_replaceErrProtected(t *testing.T) {
+	_reset("/rpl")
+	testError(
+		t,
+		"ssmenv replace --path /rpl --protect /rpl/b* foo=v1",
+		lib.ErrProtected{Name: "/rpl/bar", Pattern: "/rpl/b*"},
+	)
+}
+
+func TestCLI_Run_replaceErrTooManyDeletes(t *testing.T) {
+	_reset("/rpl")
+	testError(
+		t,
+		"ssmenv replace --path /rpl --recursive --max-deletes 2 foo=v1",
+		lib.ErrTooManyDeletes{Count: 3, Max: 2},
+	)
+}
+
+func TestCLI_Run_replaceErrDeleteFraction(t *testing.T) {
+	_reset("/rpl")
+	testError(
+		t,
+		"ssmenv replace --path /rpl --recursive foo=v1",
+		lib.ErrDeleteFraction{Count: 3, Existing: 4, Max: 0.5},
+	)
+	if len(_get("/rpl")) != len(initialParams["/rpl"]) {
+		t.Errorf("parameters must not be deleted")
+	}
+}
+
 func testError(t *testing.T, command string, want error) {
 	_, err := _runOut(command)
 	if err != want {
@@ -538,10 +1680,8 @@ func _run(command string) {
 
 func _runOut(command string) (string, error) {
 	w := new(bytes.Buffer)
-	if err := (CLI{output: w}).Run(_parseCommand(command)); err != nil {
-		return "", err
-	}
-	return w.String(), nil
+	err := (CLI{output: w}).Run(_parseCommand(command))
+	return w.String(), err
 }
 
 func _runIn(command string, stdin string) {
@@ -555,6 +1695,14 @@ func _runIn(command string, stdin string) {
 	panicIfError(<-errCh)
 }
 
+func _tempFile(name string, content string) string {
+	dir, err := ioutil.TempDir("", "ssmenv")
+	panicIfError(err)
+	file := filepath.Join(dir, name)
+	panicIfError(ioutil.WriteFile(file, []byte(content), 0600))
+	return file
+}
+
 func _parseCommand(command string) []string {
 	args, err := shellwords.Parse(command)
 	panicIfError(err)
@@ -589,7 +1737,7 @@ func _reset(path string) {
 
 	recursive := path != ""
 
-	panicIfError(lib.ReplaceParameters(svc, path, recursive, params, nil))
+	panicIfError(lib.ReplaceParameters(svc, path, recursive, params, nil, lib.UpdateOptions{}))
 }
 
 func _p(name, _type, value string) *ssm.Parameter {
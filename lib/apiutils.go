@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"io"
 	"sync"
+	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/service/ssm"
@@ -12,10 +13,19 @@ import (
 
 const maxNames = 10
 
+// maxDescribeNames is the max number of values of a DescribeParameters filter.
+const maxDescribeNames = 50
+
 // MaxConnection is the max number of concurrent connections to the AWS API.
 var MaxConnection = 4
 
-func describeParameters(svc *ssm.SSM, paths []string, recursive bool) ([]*ssm.ParameterMetadata, error) {
+// describeParameters returns the metadata of the parameters of the paths which also match the filters.
+func describeParameters(
+	svc *ssm.SSM,
+	paths []string,
+	recursive bool,
+	filters ...*ssm.ParameterStringFilter,
+) ([]*ssm.ParameterMetadata, error) {
 	for i, path := range paths {
 		if path == "" {
 			path = "/"
@@ -32,11 +42,11 @@ func describeParameters(svc *ssm.SSM, paths []string, recursive bool) ([]*ssm.Pa
 	}
 	input := ssm.DescribeParametersInput{
 		MaxResults: aws.Int64(50),
-		ParameterFilters: []*ssm.ParameterStringFilter{{
+		ParameterFilters: append([]*ssm.ParameterStringFilter{{
 			Key:    aws.String("Path"),
 			Option: &option,
 			Values: aws.StringSlice(paths),
-		}},
+		}}, filters...),
 	}
 	var metas []*ssm.ParameterMetadata
 	fn := func(output *ssm.DescribeParametersOutput, _ bool) bool {
@@ -49,13 +59,35 @@ func describeParameters(svc *ssm.SSM, paths []string, recursive bool) ([]*ssm.Pa
 	return metas, nil
 }
 
-func getParametersByPaths(svc *ssm.SSM, paths []string, recursive bool) ([]*ssm.Parameter, error) {
+// describeParameter returns the metadata of the parameter, or nil if it does not exist.
+func describeParameter(svc *ssm.SSM, name string) (*ssm.ParameterMetadata, error) {
+	if err := validateName(name); err != nil {
+		return nil, err
+	}
+
+	output, err := svc.DescribeParameters(&ssm.DescribeParametersInput{
+		ParameterFilters: []*ssm.ParameterStringFilter{{
+			Key:    aws.String("Name"),
+			Option: aws.String("Equals"),
+			Values: []*string{aws.String(abs(name))},
+		}},
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(output.Parameters) == 0 {
+		return nil, nil
+	}
+	return output.Parameters[0], nil
+}
+
+func getParametersByPaths(svc *ssm.SSM, paths []string, recursive bool, filter Filter) ([]*ssm.Parameter, error) {
 	paramsSlice := make([][]*ssm.Parameter, len(paths))
 	sem := semaphore.New(MaxConnection)
 	for i, path := range paths {
 		i, path := i, path
 		sem.Go(func() error {
-			params, err := GetParametersByPath(svc, path, recursive)
+			params, err := getFilteredParameters(svc, path, recursive, filter, true)
 			if err != nil {
 				return err
 			}
@@ -75,18 +107,28 @@ func getParametersByPaths(svc *ssm.SSM, paths []string, recursive bool) ([]*ssm.
 }
 
 // GetParametersByPath is a wrapper of SSM.GetParametersByPath()
+// The path may end with a version or label selector like /Prod:release.
 func GetParametersByPath(svc *ssm.SSM, path string, recursive bool) ([]*ssm.Parameter, error) {
+	return getParametersByPath(svc, path, recursive, true)
+}
+
+// getParametersByPath is GetParametersByPath which leaves SecureString values encrypted unless decrypt.
+func getParametersByPath(svc *ssm.SSM, path string, recursive bool, decrypt bool) ([]*ssm.Parameter, error) {
+	path, selector := splitSelector(path)
 	if path == "" {
 		path = "/"
 	}
 	if err := validatePath(path); err != nil {
 		return nil, err
 	}
+	if selector != "" {
+		return getParametersBySelector(svc, path, recursive, selector, decrypt)
+	}
 
 	input := ssm.GetParametersByPathInput{
 		Path:           &path,
 		Recursive:      &recursive,
-		WithDecryption: aws.Bool(true),
+		WithDecryption: &decrypt,
 	}
 	var params []*ssm.Parameter
 	fn := func(output *ssm.GetParametersByPathOutput, _ bool) bool {
@@ -99,10 +141,37 @@ func GetParametersByPath(svc *ssm.SSM, path string, recursive bool) ([]*ssm.Para
 	return params, nil
 }
 
+// getParametersBySelector returns the selected versions of the parameters of the path.
+// Parameters without the version or label are omitted.
+func getParametersBySelector(
+	svc *ssm.SSM,
+	path string,
+	recursive bool,
+	selector string,
+	decrypt bool,
+) ([]*ssm.Parameter, error) {
+	metas, err := describeParameters(svc, []string{path}, recursive)
+	if err != nil {
+		return nil, err
+	}
+
+	names := make([]*string, len(metas))
+	for i, meta := range metas {
+		names[i] = aws.String(*meta.Name + ":" + selector)
+	}
+	return getParametersByNames(svc, names, decrypt)
+}
+
 // GetParametersByNames is a wrapper of SSM.GetParameters()
+// Names may end with a version or label selector.
 func GetParametersByNames(svc *ssm.SSM, names []*string) ([]*ssm.Parameter, error) {
+	return getParametersByNames(svc, names, true)
+}
+
+// getParametersByNames is GetParametersByNames which leaves SecureString values encrypted unless decrypt.
+func getParametersByNames(svc *ssm.SSM, names []*string, decrypt bool) ([]*ssm.Parameter, error) {
 	for _, name := range names {
-		if err := validateName(*name); err != nil {
+		if err := validateNameWithSelector(*name); err != nil {
 			return nil, err
 		}
 	}
@@ -119,7 +188,7 @@ func GetParametersByNames(svc *ssm.SSM, names []*string) ([]*ssm.Parameter, erro
 		sem.Go(func() error {
 			output, err := svc.GetParameters(&ssm.GetParametersInput{
 				Names:          ns,
-				WithDecryption: aws.Bool(true),
+				WithDecryption: &decrypt,
 			})
 			if err != nil {
 				return err
@@ -137,89 +206,117 @@ func GetParametersByNames(svc *ssm.SSM, names []*string) ([]*ssm.Parameter, erro
 	return params, nil
 }
 
-// nolint: gocyclo
+// newPrintln returns a goroutine-safe println to log, or a no-op if log is nil.
+func newPrintln(log io.Writer) func(string) {
+	if log == nil {
+		return func(string) {}
+	}
+	var mu sync.Mutex
+	return func(mes string) {
+		mu.Lock()
+		defer mu.Unlock()
+		fmt.Fprintln(log, mes)
+	}
+}
+
+// describeParametersByNames returns the metadata of the existing parameters of names.
+func describeParametersByNames(svc *ssm.SSM, names []*string) ([]*ssm.ParameterMetadata, error) {
+	var metas []*ssm.ParameterMetadata
+	for i := 0; i < len(names); i += maxDescribeNames {
+		ns := names[i:]
+		if len(ns) > maxDescribeNames {
+			ns = ns[:maxDescribeNames]
+		}
+		values := make([]*string, len(ns))
+		for j, name := range ns {
+			values[j] = aws.String(abs(*name))
+		}
+		input := ssm.DescribeParametersInput{
+			MaxResults: aws.Int64(50),
+			ParameterFilters: []*ssm.ParameterStringFilter{{
+				Key:    aws.String("Name"),
+				Option: aws.String("Equals"),
+				Values: values,
+			}},
+		}
+		fn := func(output *ssm.DescribeParametersOutput, _ bool) bool {
+			metas = append(metas, output.Parameters...)
+			return true
+		}
+		if err := svc.DescribeParametersPages(&input, fn); err != nil {
+			return nil, err
+		}
+	}
+	return metas, nil
+}
+
+// updateParameters puts params and deletes deleteNames.
+// The options give the defaults and the policies of params.
 func updateParameters(
 	svc *ssm.SSM,
-	params []*ssm.Parameter,
+	params []*fullParameter,
 	names []*string,
 	deleteNames []*string,
 	log io.Writer,
+	opts UpdateOptions,
 ) error {
-	var println func(string)
-	if log == nil {
-		println = func(string) {}
-	} else {
-		var mu sync.Mutex
-		println = func(mes string) {
-			mu.Lock()
-			defer mu.Unlock()
-			fmt.Fprintln(log, mes)
-		}
+	if err := opts.setDefaults(params); err != nil {
+		return err
 	}
 
-	oldParams, err := GetParametersByNames(svc, names)
+	policies, err := opts.policies(time.Now())
 	if err != nil {
 		return err
 	}
-	oldParamsByName := make(map[string]*ssm.Parameter)
-	for _, oldParam := range oldParams {
-		oldParamsByName[abs(*oldParam.Name)] = oldParam
-	}
 
-	sem := semaphore.New(MaxConnection)
-
-	for _, param := range params {
-		expr, err := newExpression(param).log()
-		if err != nil {
-			return err
-		}
-
-		if old, ok := oldParamsByName[abs(*param.Name)]; ok && *param.Type == *old.Type && *param.Value == *old.Value {
-			println("UNCHANGED " + expr)
-			continue
-		}
+	setPolicies(params, policies)
 
-		if err := validateName(*param.Name); err != nil {
-			return err
-		}
-
-		param := param
-		sem.Go(func() error {
-			_, err := svc.PutParameter(&ssm.PutParameterInput{
-				Name:      param.Name,
-				Value:     param.Value,
-				Type:      param.Type,
-				Overwrite: aws.Bool(true),
-			})
-			if err != nil {
-				return err
-			}
-			println("PUT " + expr)
-			return nil
-		})
+	changes, err := planChanges(svc, params, names, deleteNames)
+	if err != nil {
+		return err
 	}
 
-	for _, name := range deleteNames {
-		if err := validateName(*name); err != nil {
-			return err
-		}
+	if err := checkSizes(changes); err != nil {
+		return err
+	}
+	if err := checkPatterns(changes); err != nil {
+		return err
+	}
 
-		name := name
-		sem.Go(func() error {
-			_, err := svc.DeleteParameter(&ssm.DeleteParameterInput{Name: name})
-			if err != nil {
-				return err
-			}
-			println("DELETE " + abs(*name))
-			return nil
-		})
+	if err := opts.checkDeletes(changes, len(names)+len(deleteNames)); err != nil {
+		return err
 	}
 
-	return sem.Wait()
+	if opts.DryRun {
+		return printPlan(changes, log)
+	}
+	return applyChanges(svc, changes, log, opts)
 }
 
 // ReplaceParameters replaces all the parameters of the given path.
-func ReplaceParameters(svc *ssm.SSM, path string, recursive bool, params []*ssm.Parameter, log io.Writer) error {
+func ReplaceParameters(
+	svc *ssm.SSM,
+	path string,
+	recursive bool,
+	params []*ssm.Parameter,
+	log io.Writer,
+	opts UpdateOptions,
+) error {
+	fullParams := make([]*fullParameter, len(params))
+	for i, param := range params {
+		fullParams[i] = newFullParameter(param)
+	}
+	return replaceParameters(svc, path, recursive, fullParams, log, opts)
+}
+
+func replaceParameters(
+	svc *ssm.SSM,
+	path string,
+	recursive bool,
+	params []*fullParameter,
+	log io.Writer,
+	opts UpdateOptions,
+) error {
 	oldMetas, err := describeParameters(svc, []string{path}, recursive)
 	if err != nil {
 		return err
@@ -227,7 +324,7 @@ func ReplaceParameters(svc *ssm.SSM, path string, recursive bool, params []*ssm.
 
 	nameExists := make(map[string]bool)
 	for _, param := range params {
-		nameExists[abs(*param.Name)] = true
+		nameExists[abs(param.Name)] = true
 	}
 
 	var names []*string
@@ -240,5 +337,5 @@ func ReplaceParameters(svc *ssm.SSM, path string, recursive bool, params []*ssm.
 		}
 	}
 
-	return updateParameters(svc, params, names, deleteNames, log)
+	return updateParameters(svc, params, names, deleteNames, log, opts)
 }
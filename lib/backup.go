@@ -0,0 +1,109 @@
+package lib
+
+import (
+	"encoding/json"
+	"io"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/service/ssm"
+	"github.com/m4i/ssmenv/semaphore"
+)
+
+const backupVersion = 1
+
+// A backupDocument is a snapshot of parameters with their metadata.
+type backupDocument struct {
+	Version    int
+	Parameters []*fullParameter
+}
+
+// putFullParameters puts the parameters with their metadata and tags.
+func putFullParameters(svc *ssm.SSM, fulls []*fullParameter, log io.Writer) error {
+	println := newPrintln(log)
+
+	for _, full := range fulls {
+		if err := validateName(full.Name); err != nil {
+			return err
+		}
+	}
+
+	sem := semaphore.New(MaxConnection)
+	for _, full := range fulls {
+		expr, err := full.expression().log()
+		if err != nil {
+			return err
+		}
+
+		full := full
+		sem.Go(func() error {
+			if err := putFullParameter(svc, full); err != nil {
+				return err
+			}
+			println("PUT " + expr)
+			return nil
+		})
+	}
+	return sem.Wait()
+}
+
+// rewritePath replaces the leading path of a name by the first matching rewrite of the form "/old=/new".
+func rewritePath(name string, rewrites []string) (string, error) {
+	for _, rewrite := range rewrites {
+		sides := strings.SplitN(rewrite, "=", 2)
+		if len(sides) != 2 {
+			return "", ErrInvalidPathRewrite{Rewrite: rewrite}
+		}
+		from := strings.TrimSuffix(sides[0], "/")
+		to := strings.TrimSuffix(sides[1], "/")
+		absName := abs(name)
+		if absName == from {
+			return abs(to), nil
+		}
+		if strings.HasPrefix(absName, from+"/") {
+			return to + absName[len(from):], nil
+		}
+	}
+	return name, nil
+}
+
+// Backup is the implementation of `ssmenv backup`.
+// The backup is encrypted if the passphrase is given.
+func Backup(w io.Writer, svc *ssm.SSM, path string, recursive bool, passphrase []byte) error {
+	fulls, err := getFullParameters(svc, []string{path}, recursive)
+	if err != nil {
+		return err
+	}
+
+	data, err := json.MarshalIndent(backupDocument{Version: backupVersion, Parameters: fulls}, "", "  ")
+	if err != nil {
+		return err
+	}
+	return encryptTo(w, append(data, '\n'), passphrase)
+}
+
+// Restore is the implementation of `ssmenv restore`.
+// An encrypted backup is decrypted with the passphrase.
+func Restore(w io.Writer, svc *ssm.SSM, r io.Reader, rewrites []string, passphrase []byte) error {
+	r, err := DecryptReader(r, passphrase)
+	if err != nil {
+		return err
+	}
+
+	var backup backupDocument
+	if err := json.NewDecoder(r).Decode(&backup); err != nil {
+		return err
+	}
+	if backup.Version != backupVersion {
+		return ErrUnsupportedBackup{Version: backup.Version}
+	}
+
+	for _, full := range backup.Parameters {
+		name, err := rewritePath(full.Name, rewrites)
+		if err != nil {
+			return err
+		}
+		full.Name = name
+	}
+
+	return putFullParameters(svc, backup.Parameters, w)
+}
@@ -0,0 +1,31 @@
+package lib
+
+import (
+	"io/ioutil"
+	"os"
+
+	yaml "gopkg.in/yaml.v2"
+)
+
+// A Config is the content of a config file.
+type Config struct {
+	// KMSKeyIDs are the KMS key IDs of SecureString parameters by path.
+	KMSKeyIDs map[string]string `yaml:"kms_key_ids"`
+}
+
+// LoadConfig reads a YAML config file.
+// An empty config is returned if the file does not exist and missingOK.
+func LoadConfig(filename string, missingOK bool) (Config, error) {
+	var config Config
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		if missingOK && os.IsNotExist(err) {
+			return config, nil
+		}
+		return config, err
+	}
+	if err := yaml.Unmarshal(data, &config); err != nil {
+		return config, err
+	}
+	return config, nil
+}
@@ -0,0 +1,173 @@
+package lib
+
+import (
+	"io"
+	gopath "path"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ssm"
+)
+
+// CopyOptions are options for copy and move.
+type CopyOptions struct {
+	// Recursive copies all parameters within the hierarchy of src.
+	Recursive bool
+
+	// Overwrite replaces existing destination parameters.
+	Overwrite bool
+
+	// SkipExisting leaves existing destination parameters as they are.
+	SkipExisting bool
+
+	// SameSide tells that src and dst are in the same region and account, where they must differ.
+	SameSide bool
+
+	// KeyID and PathKeyIDs choose the KMS keys of SecureString parameters copied to another side as in UpdateOptions,
+	// since the keys of src do not exist there. The keys of src are kept on the same side.
+	KeyID      string
+	PathKeyIDs map[string]string
+}
+
+// Copy is the implementation of `ssmenv copy`.
+// src is a parameter, or a path if no such parameter exists or opts.Recursive is given.
+func Copy(w io.Writer, srcSvc *ssm.SSM, src string, dstSvc *ssm.SSM, dst string, opts CopyOptions) error {
+	_, err := copyParameters(w, srcSvc, src, dstSvc, dst, opts)
+	return err
+}
+
+// Move is the implementation of `ssmenv move`.
+// The source parameters are deleted only after all of them are copied.
+func Move(w io.Writer, srcSvc *ssm.SSM, src string, dstSvc *ssm.SSM, dst string, opts CopyOptions) error {
+	copied, err := copyParameters(w, srcSvc, src, dstSvc, dst, opts)
+	if err != nil {
+		return err
+	}
+	return updateParameters(srcSvc, nil, nil, copied, w, UpdateOptions{})
+}
+
+// copyParameters copies the parameters of src to dst, and returns the names of the copied source parameters.
+func copyParameters(
+	w io.Writer,
+	srcSvc *ssm.SSM,
+	src string,
+	dstSvc *ssm.SSM,
+	dst string,
+	opts CopyOptions,
+) ([]*string, error) {
+	if opts.SameSide && gopath.Clean(abs(src)) == gopath.Clean(abs(dst)) {
+		return nil, ErrSameSrcAndDst
+	}
+
+	fulls, dstNames, err := planCopy(srcSvc, src, dst, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	exists, err := existingNames(dstSvc, dstNames)
+	if err != nil {
+		return nil, err
+	}
+
+	println := newPrintln(w)
+	var copies []*fullParameter
+	var copied []*string
+	for i, full := range fulls {
+		dstName := dstNames[i]
+		if exists[abs(dstName)] && !opts.Overwrite {
+			if !opts.SkipExisting {
+				return nil, ErrParameterExists{Name: abs(dstName)}
+			}
+			println("SKIP " + abs(dstName))
+			continue
+		}
+		copied = append(copied, aws.String(full.Name))
+		copies = append(copies, opts.dstParameter(full, dstName))
+	}
+
+	if err := putFullParameters(dstSvc, copies, w); err != nil {
+		return nil, err
+	}
+	return copied, nil
+}
+
+// existingNames returns the absolute names of the existing parameters among names.
+func existingNames(svc *ssm.SSM, names []string) (map[string]bool, error) {
+	existing, err := GetParametersByNames(svc, aws.StringSlice(names))
+	if err != nil {
+		return nil, err
+	}
+	exists := make(map[string]bool)
+	for _, param := range existing {
+		exists[abs(*param.Name)] = true
+	}
+	return exists, nil
+}
+
+// dstParameter returns the copy of a source parameter named dstName.
+// SecureString parameters copied to another side are encrypted with the key of opts.
+func (opts CopyOptions) dstParameter(full *fullParameter, dstName string) *fullParameter {
+	dstFull := *full
+	dstFull.Name = dstName
+	if !opts.SameSide && dstFull.Type == ssm.ParameterTypeSecureString {
+		dstFull.KeyID = UpdateOptions{KeyID: opts.KeyID, PathKeyIDs: opts.PathKeyIDs}.keyID(dstName)
+	}
+	return &dstFull
+}
+
+// planCopy returns the source parameters and the corresponding destination names.
+// It fails if a parameter would be copied onto itself.
+func planCopy(svc *ssm.SSM, src string, dst string, opts CopyOptions) ([]*fullParameter, []string, error) {
+	fulls, dstNames, err := planCopyNames(svc, src, dst, opts.Recursive)
+	if err != nil || !opts.SameSide {
+		return fulls, dstNames, err
+	}
+	for i, full := range fulls {
+		if abs(full.Name) == abs(dstNames[i]) {
+			return nil, nil, ErrSameSrcAndDst
+		}
+	}
+	return fulls, dstNames, nil
+}
+
+// planCopyNames returns the source parameters and the corresponding destination names.
+func planCopyNames(svc *ssm.SSM, src string, dst string, recursive bool) ([]*fullParameter, []string, error) {
+	if !recursive {
+		fulls, err := planCopyParameter(svc, src, dst)
+		if err != nil || fulls != nil {
+			return fulls, []string{dst}, err
+		}
+	}
+
+	if err := validatePath(dst); err != nil {
+		return nil, nil, err
+	}
+	fulls, err := getFullParameters(svc, []string{src}, recursive)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(fulls) == 0 {
+		return nil, nil, ErrParameterNotFound{Name: src}
+	}
+
+	dstNames := make([]string, len(fulls))
+	for i, full := range fulls {
+		name, err := rel(full.Name, src)
+		if err != nil {
+			return nil, nil, err
+		}
+		dstNames[i] = gopath.Join(dst, name)
+	}
+	return fulls, dstNames, nil
+}
+
+// planCopyParameter returns the source parameter as planCopyNames does, or nil if src is not a parameter.
+func planCopyParameter(svc *ssm.SSM, src string, dst string) ([]*fullParameter, error) {
+	meta, err := describeParameter(svc, src)
+	if err != nil || meta == nil {
+		return nil, err
+	}
+	if err := validateName(dst); err != nil {
+		return nil, err
+	}
+	return fullParametersOf(svc, []*ssm.ParameterMetadata{meta})
+}
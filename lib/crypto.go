@@ -0,0 +1,158 @@
+package lib
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+
+	"golang.org/x/crypto/scrypt"
+)
+
+const cipherName = "scrypt+aes-256-gcm"
+
+// encryptedVersion is the version of encryptedDocument, which also marks a document as encrypted.
+const encryptedVersion = 1
+
+// Bounds of the scrypt parameters accepted from an encrypted document, which is not trusted until it is opened.
+// scrypt allocates 128*N*r bytes, so N*r is bounded to take 128 MiB at most.
+const (
+	maxScryptNR = 1 << 20
+	maxScryptP  = 2
+	saltSize    = 16
+)
+
+// An encryptedDocument is data encrypted with a key derived from a passphrase.
+type encryptedDocument struct {
+	Version    int `json:"ssmenv-encrypted"`
+	Cipher     string
+	N, R, P    int
+	Salt       []byte
+	Nonce      []byte
+	Ciphertext []byte
+}
+
+func deriveKey(passphrase, salt []byte, n, r, p int) (cipher.AEAD, error) {
+	key, err := scrypt.Key(passphrase, salt, n, r, p, 32)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// Encrypt encrypts data with a key derived from the passphrase.
+func Encrypt(data, passphrase []byte) ([]byte, error) {
+	if len(passphrase) == 0 {
+		return nil, ErrRequirePassphrase
+	}
+
+	doc := encryptedDocument{
+		Version: encryptedVersion,
+		Cipher:  cipherName,
+		N:       1 << 15,
+		R:       8,
+		P:       1,
+		Salt:    make([]byte, saltSize),
+	}
+	if _, err := io.ReadFull(rand.Reader, doc.Salt); err != nil {
+		return nil, err
+	}
+
+	aead, err := deriveKey(passphrase, doc.Salt, doc.N, doc.R, doc.P)
+	if err != nil {
+		return nil, err
+	}
+	doc.Nonce = make([]byte, aead.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, doc.Nonce); err != nil {
+		return nil, err
+	}
+	doc.Ciphertext = aead.Seal(nil, doc.Nonce, data, nil)
+
+	return json.MarshalIndent(doc, "", "  ")
+}
+
+// valid reports whether the scrypt parameters and the salt are within the bounds.
+func (doc encryptedDocument) valid() bool {
+	return doc.N > 1 && doc.N&(doc.N-1) == 0 &&
+		doc.R > 0 && doc.N <= maxScryptNR/doc.R &&
+		doc.P > 0 && doc.P <= maxScryptP &&
+		len(doc.Salt) == saltSize
+}
+
+// Decrypt decrypts data written by Encrypt.
+func Decrypt(data, passphrase []byte) ([]byte, error) {
+	var doc encryptedDocument
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+	if doc.Version != encryptedVersion {
+		return nil, ErrInvalidEncryptedDocument
+	}
+	if doc.Cipher != cipherName {
+		return nil, ErrUnknownCipher{Cipher: doc.Cipher}
+	}
+	if !doc.valid() {
+		return nil, ErrInvalidEncryptedDocument
+	}
+	if len(passphrase) == 0 {
+		return nil, ErrRequirePassphrase
+	}
+
+	aead, err := deriveKey(passphrase, doc.Salt, doc.N, doc.R, doc.P)
+	if err != nil {
+		return nil, err
+	}
+	if len(doc.Nonce) != aead.NonceSize() {
+		return nil, ErrDecrypt
+	}
+	plaintext, err := aead.Open(nil, doc.Nonce, doc.Ciphertext, nil)
+	if err != nil {
+		return nil, ErrDecrypt
+	}
+	return plaintext, nil
+}
+
+// IsEncrypted reports whether data was written by Encrypt, which is marked by the "ssmenv-encrypted" key.
+func IsEncrypted(data []byte) bool {
+	if !bytes.HasPrefix(bytes.TrimSpace(data), []byte("{")) {
+		return false
+	}
+	var doc struct {
+		Version json.RawMessage `json:"ssmenv-encrypted"`
+	}
+	return json.Unmarshal(data, &doc) == nil && doc.Version != nil
+}
+
+// DecryptReader returns a reader of the decrypted content if r is encrypted, or of the content as it is.
+func DecryptReader(r io.Reader, passphrase []byte) (io.Reader, error) {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	if IsEncrypted(data) {
+		if data, err = Decrypt(data, passphrase); err != nil {
+			return nil, err
+		}
+	}
+	return bytes.NewReader(data), nil
+}
+
+// encryptTo writes data to w, encrypted if the passphrase is given.
+func encryptTo(w io.Writer, data, passphrase []byte) error {
+	if passphrase != nil {
+		var err error
+		if data, err = Encrypt(data, passphrase); err != nil {
+			return err
+		}
+		data = append(data, '\n')
+	}
+	_, err := w.Write(data)
+	return err
+}
@@ -0,0 +1,185 @@
+package lib
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ssm"
+)
+
+// FormatText is the human readable output format.
+const FormatText = "text"
+
+// A Snapshot is a set of parameters keyed by their names relative to a path.
+type Snapshot map[string]*fullParameter
+
+// SnapshotPath returns the parameters of the given path, which may end with a version or label selector.
+func SnapshotPath(svc *ssm.SSM, path string, recursive bool) (Snapshot, error) {
+	if path == "" {
+		path = "/"
+	}
+
+	params, err := GetParametersByPath(svc, path, recursive)
+	if err != nil {
+		return nil, err
+	}
+	path, _ = splitSelector(path)
+
+	metas, err := describeParameters(svc, []string{path}, recursive)
+	if err != nil {
+		return nil, err
+	}
+	keyIDsByName := make(map[string]string)
+	for _, meta := range metas {
+		keyIDsByName[*meta.Name] = aws.StringValue(meta.KeyId)
+	}
+
+	snapshot := make(Snapshot)
+	for _, param := range params {
+		name, err := rel(*param.Name, path)
+		if err != nil {
+			return nil, err
+		}
+		full := newFullParameter(param)
+		if full.Type == ssm.ParameterTypeSecureString {
+			full.KeyID = keyIDsByName[full.Name]
+		}
+		snapshot[name] = full
+	}
+	return snapshot, nil
+}
+
+// SnapshotFile returns the parameters of a parameter file.
+func SnapshotFile(r io.Reader, format string) (Snapshot, error) {
+	exprs, err := ParseFile(r, format, SecureMatcher{})
+	if err != nil {
+		return nil, err
+	}
+
+	exprObjs, err := parseExpressions(exprs)
+	if err != nil {
+		return nil, err
+	}
+
+	snapshot := make(Snapshot)
+	for _, exprObj := range exprObjs {
+		param, err := exprObj.parameter("")
+		if err != nil {
+			return nil, err
+		}
+		snapshot[exprObj.Name] = param
+	}
+	return snapshot, nil
+}
+
+type diffValue struct {
+	Type  string
+	Value string
+	KeyID string `json:"KeyId,omitempty"`
+}
+
+type diffEntry struct {
+	Name        string
+	Source      *diffValue `json:",omitempty"`
+	Destination *diffValue `json:",omitempty"`
+}
+
+type diffResult struct {
+	Added   []diffEntry
+	Removed []diffEntry
+	Changed []diffEntry
+	all     []diffEntry
+}
+
+func newDiffValue(param *fullParameter, showValues bool) *diffValue {
+	if param == nil {
+		return nil
+	}
+	value := param.Value
+	if !showValues {
+		value = param.expression().maskedValue()
+	}
+	return &diffValue{Type: param.Type, Value: value, KeyID: param.KeyID}
+}
+
+func diffSnapshots(src, dst Snapshot, showValues bool) diffResult {
+	names := make(map[string]bool)
+	for name := range src {
+		names[name] = true
+	}
+	for name := range dst {
+		names[name] = true
+	}
+	sorted := make([]string, 0, len(names))
+	for name := range names {
+		sorted = append(sorted, name)
+	}
+	sort.Strings(sorted)
+
+	result := diffResult{Added: []diffEntry{}, Removed: []diffEntry{}, Changed: []diffEntry{}}
+	for _, name := range sorted {
+		s, d := src[name], dst[name]
+		entry := diffEntry{
+			Name:        name,
+			Source:      newDiffValue(s, showValues),
+			Destination: newDiffValue(d, showValues),
+		}
+		switch {
+		case s == nil:
+			result.Added = append(result.Added, entry)
+		case d == nil:
+			result.Removed = append(result.Removed, entry)
+		case d.differs(s):
+			result.Changed = append(result.Changed, entry)
+		default:
+			continue
+		}
+		result.all = append(result.all, entry)
+	}
+	return result
+}
+
+// Diff is the implementation of `ssmenv diff`.
+func Diff(w io.Writer, src, dst Snapshot, showValues bool, format string) error {
+	result := diffSnapshots(src, dst, showValues)
+
+	switch format {
+	case FormatJSON:
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(result)
+	case FormatText:
+	default:
+		return ErrUnknownFormat{Format: format}
+	}
+
+	for _, entry := range result.all {
+		if entry.Source != nil {
+			line, err := entry.Source.line("- ", entry.Name)
+			if err != nil {
+				return err
+			}
+			fmt.Fprintln(w, line)
+		}
+		if entry.Destination != nil {
+			line, err := entry.Destination.line("+ ", entry.Name)
+			if err != nil {
+				return err
+			}
+			fmt.Fprintln(w, line)
+		}
+	}
+	return nil
+}
+
+// line returns the value as an expression, in which the default KMS key is omitted.
+func (v *diffValue) line(prefix, name string) (string, error) {
+	keyID := v.KeyID
+	if keyID == defaultKeyID {
+		keyID = ""
+	}
+	return buildExpr(prefix, name, v.Value, typeMark(v.Type, keyID))
+}
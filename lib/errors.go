@@ -3,6 +3,8 @@ package lib
 import (
 	"errors"
 	"fmt"
+	"strings"
+	"time"
 )
 
 // Errors with a fixed message.
@@ -10,6 +12,16 @@ var (
 	ErrRequireCommand      = errors.New("command is required")
 	ErrRequireNameAndValue = errors.New("name=value is required")
 	ErrRequirePath         = errors.New("path is required")
+	ErrRequirePassphrase   = errors.New("passphrase is required")
+	ErrDecrypt             = errors.New("failed to decrypt: wrong passphrase or corrupted data")
+	ErrChangesPending      = errors.New("changes are pending")
+	ErrAborted             = errors.New("aborted")
+	ErrRequireNameAndLabel = errors.New("name and label are required")
+	ErrSameSrcAndDst       = errors.New("src and dst must be different")
+
+	ErrNotifyBeforeWithoutExpiration = errors.New("an expiration notification requires an expiration")
+	ErrInvalidEncryptedDocument      = errors.New("invalid encrypted document")
+	ErrLabelWithSelector             = errors.New("a label filter can not be given with a selector")
 )
 
 // ErrSlashWithoutRecursive describes that a name contains slashes without a recrusive flag.
@@ -30,6 +42,24 @@ func (e ErrInvalidName) Error() string {
 	return fmt.Sprintf("invalid name: %v", e.Name)
 }
 
+// ErrInvalidAnnotation records an error for an unknown or malformed annotation.
+type ErrInvalidAnnotation struct {
+	Annotation string
+}
+
+func (e ErrInvalidAnnotation) Error() string {
+	return fmt.Sprintf("invalid annotation: %v", e.Annotation)
+}
+
+// ErrDanglingAnnotation describes that an annotation is not followed by an expression.
+type ErrDanglingAnnotation struct {
+	Annotation string
+}
+
+func (e ErrDanglingAnnotation) Error() string {
+	return fmt.Sprintf("an annotation must be followed by an expression: %v", e.Annotation)
+}
+
 // ErrInvalidPath records an error for an invalid path.
 type ErrInvalidPath struct {
 	Path string
@@ -75,3 +105,297 @@ type ErrUnmarshal struct {
 func (e ErrUnmarshal) Error() string {
 	return fmt.Sprintf("invalid value %#v: %s", e.value, e.cause.Error())
 }
+
+// ErrUnknownFormat describes an unknown format of a parameter file.
+type ErrUnknownFormat struct {
+	Format string
+}
+
+func (e ErrUnknownFormat) Error() string {
+	return fmt.Sprintf("unknown format: %#v", e.Format)
+}
+
+// ErrInvalidLine describes a problem parsing a line of a parameter file.
+type ErrInvalidLine struct {
+	Format string
+	Line   int
+	Text   string
+}
+
+func (e ErrInvalidLine) Error() string {
+	return fmt.Sprintf("invalid %s at line %d: %#v", e.Format, e.Line, e.Text)
+}
+
+// ErrUnsupportedValue describes a value of a parameter file which can not be a parameter.
+type ErrUnsupportedValue struct {
+	Key   string
+	Value string
+}
+
+func (e ErrUnsupportedValue) Error() string {
+	return fmt.Sprintf("unsupported value for %#v: %s", e.Key, e.Value)
+}
+
+// ErrInvalidPathRewrite describes a path rewrite which is not "/old=/new".
+type ErrInvalidPathRewrite struct {
+	Rewrite string
+}
+
+func (e ErrInvalidPathRewrite) Error() string {
+	return fmt.Sprintf(`a path rewrite must be "/old=/new": %#v`, e.Rewrite)
+}
+
+// ErrUnsupportedBackup describes a backup written in an unsupported version.
+type ErrUnsupportedBackup struct {
+	Version int
+}
+
+func (e ErrUnsupportedBackup) Error() string {
+	return fmt.Sprintf("unsupported backup version: %d", e.Version)
+}
+
+// ErrUnknownCipher describes an encrypted file with an unknown cipher.
+type ErrUnknownCipher struct {
+	Cipher string
+}
+
+func (e ErrUnknownCipher) Error() string {
+	return fmt.Sprintf("unknown cipher: %#v", e.Cipher)
+}
+
+// ErrProtected describes that a protected parameter would be deleted.
+type ErrProtected struct {
+	Name, Pattern string
+}
+
+func (e ErrProtected) Error() string {
+	return fmt.Sprintf("a protected parameter can not be deleted: name=%#v, pattern=%#v", e.Name, e.Pattern)
+}
+
+// ErrTooManyDeletes describes that more parameters than the limit would be deleted.
+type ErrTooManyDeletes struct {
+	Count, Max int
+}
+
+func (e ErrTooManyDeletes) Error() string {
+	return fmt.Sprintf("%d parameters would be deleted, more than the limit %d", e.Count, e.Max)
+}
+
+// ErrDeleteFraction describes that too large a fraction of existing parameters would be deleted.
+type ErrDeleteFraction struct {
+	Count, Existing int
+	Max             float64
+}
+
+func (e ErrDeleteFraction) Error() string {
+	return fmt.Sprintf(
+		"%d of %d parameters would be deleted, more than the fraction %v without a force flag",
+		e.Count, e.Existing, e.Max,
+	)
+}
+
+// ErrPartialUpdate describes an update which failed partway, and the result of rolling it back.
+type ErrPartialUpdate struct {
+	Cause         error
+	RolledBack    []string
+	NotRolledBack []string
+}
+
+func (e ErrPartialUpdate) Error() string {
+	mes := fmt.Sprintf("failed to update parameters: %v", e.Cause)
+	if len(e.RolledBack) > 0 {
+		mes += "; rolled back: " + strings.Join(e.RolledBack, ", ")
+	}
+	if len(e.NotRolledBack) > 0 {
+		mes += "; could not roll back: " + strings.Join(e.NotRolledBack, ", ")
+	}
+	return mes
+}
+
+// ErrVerify describes that a put parameter can not be read with the new value.
+type ErrVerify struct {
+	Name string
+}
+
+func (e ErrVerify) Error() string {
+	return fmt.Sprintf("failed to verify the new value: %v", e.Name)
+}
+
+// ErrParameterExists describes that a destination parameter already exists.
+type ErrParameterExists struct {
+	Name string
+}
+
+func (e ErrParameterExists) Error() string {
+	return fmt.Sprintf("parameter already exists: %v", e.Name)
+}
+
+// ErrParameterNotFound describes that neither a parameter nor a path exists.
+type ErrParameterNotFound struct {
+	Name string
+}
+
+func (e ErrParameterNotFound) Error() string {
+	return fmt.Sprintf("parameter not found: %v", e.Name)
+}
+
+// ErrInvalidTime describes a point in time which can not be parsed.
+type ErrInvalidTime struct {
+	Value string
+}
+
+func (e ErrInvalidTime) Error() string {
+	return fmt.Sprintf("invalid time: %#v", e.Value)
+}
+
+// ErrInvalidDuration describes a duration which can not be parsed or used.
+type ErrInvalidDuration struct {
+	Value string
+}
+
+func (e ErrInvalidDuration) Error() string {
+	return fmt.Sprintf("invalid duration: %#v", e.Value)
+}
+
+// ErrValueTooLarge describes a value which is too large for the tier of its parameter.
+type ErrValueTooLarge struct {
+	Name string
+	Size int
+	Tier string
+	Max  int
+}
+
+func (e ErrValueTooLarge) Error() string {
+	return fmt.Sprintf("the value of %v is %d bytes, which exceeds %d bytes of the %v tier", e.Name, e.Size, e.Max, e.Tier)
+}
+
+// ErrPoliciesInStandard describes a parameter given policies in the Standard tier, which does not support them.
+type ErrPoliciesInStandard struct {
+	Name string
+}
+
+func (e ErrPoliciesInStandard) Error() string {
+	return fmt.Sprintf("policies can not be given to %v in the Standard tier", e.Name)
+}
+
+// ErrTierDowngrade describes an Advanced parameter put in the Standard tier, which SSM does not allow.
+type ErrTierDowngrade struct {
+	Name string
+}
+
+func (e ErrTierDowngrade) Error() string {
+	return fmt.Sprintf("%v can not be downgraded from the Advanced tier to Standard", e.Name)
+}
+
+// ErrUnknownTier describes an unknown tier.
+type ErrUnknownTier struct {
+	Tier string
+}
+
+func (e ErrUnknownTier) Error() string {
+	return fmt.Sprintf("unknown tier: %#v", e.Tier)
+}
+
+// ErrInvalidSchema describes an invalid rule of a schema.
+type ErrInvalidSchema struct {
+	Pattern string
+	Reason  string
+}
+
+func (e ErrInvalidSchema) Error() string {
+	return fmt.Sprintf("invalid schema of %v: %v", e.Pattern, e.Reason)
+}
+
+// ErrSchemaViolations describes parameters which violate a schema.
+type ErrSchemaViolations struct {
+	Violations []string
+}
+
+func (e ErrSchemaViolations) Error() string {
+	return "parameters violate the schema:\n  " + strings.Join(e.Violations, "\n  ")
+}
+
+// ErrPatternMismatch describes a value which does not match the allowed pattern of its parameter.
+type ErrPatternMismatch struct {
+	Name    string
+	Pattern string
+}
+
+func (e ErrPatternMismatch) Error() string {
+	return fmt.Sprintf("the value of %v does not match the allowed pattern: %v", e.Name, e.Pattern)
+}
+
+// ErrInvalidTag describes a tag which is not key=value.
+type ErrInvalidTag struct {
+	Tag string
+}
+
+func (e ErrInvalidTag) Error() string {
+	return fmt.Sprintf("invalid tag: %#v", e.Tag)
+}
+
+// ErrVersionNotFound describes that a parameter does not have the version.
+type ErrVersionNotFound struct {
+	Name    string
+	Version int64
+}
+
+func (e ErrVersionNotFound) Error() string {
+	return fmt.Sprintf("version %d of %v not found", e.Version, e.Name)
+}
+
+// ErrHistoryNotRetained describes that the history of a parameter before a point in time is no longer retained.
+type ErrHistoryNotRetained struct {
+	Name string
+	At   time.Time
+}
+
+func (e ErrHistoryNotRetained) Error() string {
+	return fmt.Sprintf("history of %v before %v is not retained", e.Name, e.At.Format(time.RFC3339))
+}
+
+// ErrInvalidLabels describes labels which can not be attached to or detached from a parameter.
+type ErrInvalidLabels struct {
+	Labels []string
+}
+
+func (e ErrInvalidLabels) Error() string {
+	return fmt.Sprintf("invalid labels: %v", strings.Join(e.Labels, ", "))
+}
+
+// ErrMaskedValues describes SecureString parameters whose values would be exported or printed alone masked.
+type ErrMaskedValues struct {
+	Names []string
+}
+
+func (e ErrMaskedValues) Error() string {
+	return fmt.Sprintf("SecureString values must be revealed to be exported or printed alone: %v",
+		strings.Join(e.Names, ", "))
+}
+
+// ErrMaskedInput describes SecureString parameters whose values given are masked, e.g. in the output of get.
+type ErrMaskedInput struct {
+	Names []string
+}
+
+func (e ErrMaskedInput) Error() string {
+	return fmt.Sprintf("SecureString values are masked, get them with --reveal: %v", strings.Join(e.Names, ", "))
+}
+
+// ErrNotDeleted describes parameters which DeleteParameters failed to delete.
+type ErrNotDeleted struct {
+	Names []string
+}
+
+func (e ErrNotDeleted) Error() string {
+	return fmt.Sprintf("failed to delete: %v", strings.Join(e.Names, ", "))
+}
+
+// ErrUnknownEncoding describes an unknown encoding of secrets to redact.
+type ErrUnknownEncoding struct {
+	Encoding string
+}
+
+func (e ErrUnknownEncoding) Error() string {
+	return fmt.Sprintf("unknown encoding: %#v", e.Encoding)
+}
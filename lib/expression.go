@@ -9,12 +9,31 @@ import (
 	"github.com/aws/aws-sdk-go/service/ssm"
 )
 
-const secureMark = "@"
+// Marks at the end of the left hand side of an expression which give the type.
+// A KMS key ID may follow secureMark.
+const (
+	secureMark = "@"
+	listMark   = "[]"
+)
+
+// mask is printed instead of SecureString values unless they are revealed.
+const mask = "****************"
+
+// annotationMark begins the comment of an annotation, e.g. "# @tag owner=team-a",
+// which gives metadata to the following expression.
+const annotationMark = "@"
 
 type expression struct {
-	Name   string
-	Value  string
-	Secure bool
+	Name  string
+	Value string
+	Type  string
+	KeyID string
+
+	// Metadata given by annotations. They are left as they are if not given.
+	Description    string
+	Tier           string
+	Tags           map[string]string
+	AllowedPattern string
 }
 
 func parseExpression(expr string) (*expression, error) {
@@ -25,11 +44,7 @@ func parseExpression(expr string) (*expression, error) {
 	lhs := sides[0]
 	rhs := sides[1]
 
-	secure := false
-	if strings.HasSuffix(lhs, secureMark) {
-		lhs = lhs[:len(lhs)-1]
-		secure = true
-	}
+	lhs, _type, keyID := splitTypeMark(lhs)
 
 	value, err := unescape(rhs)
 	if err != nil {
@@ -37,17 +52,136 @@ func parseExpression(expr string) (*expression, error) {
 	}
 
 	return &expression{
-		Name:   lhs,
-		Value:  value,
-		Secure: secure,
+		Name:  lhs,
+		Value: value,
+		Type:  _type,
+		KeyID: keyID,
 	}, nil
 }
 
+// IsAnnotation reports whether the line is an annotation comment, which must be kept with expressions.
+func IsAnnotation(line string) bool {
+	return strings.HasPrefix(line, "#") && strings.HasPrefix(strings.TrimSpace(line[1:]), annotationMark)
+}
+
+// parseExpressions parses expressions, each of which may be preceded by annotations:
+//
+//	# @description The password of the database
+//	# @tag owner=team-a
+//	# @tier Advanced
+//	# @pattern ^\S{8,}$
+//	DB_PASS@=passw0rd
+func parseExpressions(exprs []string) ([]*expression, error) {
+	var exprObjs []*expression
+	annotated := &expression{}
+	var lastAnnotation string
+	for _, expr := range exprs {
+		if IsAnnotation(expr) {
+			if err := annotated.annotate(expr); err != nil {
+				return nil, err
+			}
+			lastAnnotation = expr
+			continue
+		}
+
+		exprObj, err := parseExpression(expr)
+		if err != nil {
+			return nil, err
+		}
+		exprObj.Description = annotated.Description
+		exprObj.Tier = annotated.Tier
+		exprObj.Tags = annotated.Tags
+		exprObj.AllowedPattern = annotated.AllowedPattern
+		exprObjs = append(exprObjs, exprObj)
+		annotated = &expression{}
+		lastAnnotation = ""
+	}
+	if lastAnnotation != "" {
+		return nil, ErrDanglingAnnotation{Annotation: lastAnnotation}
+	}
+	if err := checkMaskedExpressions(exprObjs); err != nil {
+		return nil, err
+	}
+	return exprObjs, nil
+}
+
+// checkMaskedExpressions fails if any SecureString value is the mask printed by get,
+// which would overwrite the real value if the output of get is written back.
+func checkMaskedExpressions(exprObjs []*expression) error {
+	var names []string
+	for _, exprObj := range exprObjs {
+		if exprObj.Type == ssm.ParameterTypeSecureString && exprObj.Value == mask {
+			names = append(names, exprObj.Name)
+		}
+	}
+	if len(names) > 0 {
+		return ErrMaskedInput{Names: names}
+	}
+	return nil
+}
+
+// annotate sets the metadata given by an annotation comment.
+func (e *expression) annotate(line string) error {
+	text := strings.TrimPrefix(strings.TrimSpace(line[1:]), annotationMark)
+	fields := strings.SplitN(text, " ", 2)
+	if len(fields) != 2 || strings.TrimSpace(fields[1]) == "" {
+		return ErrInvalidAnnotation{Annotation: line}
+	}
+	arg := strings.TrimSpace(fields[1])
+
+	switch fields[0] {
+	case "description":
+		e.Description = arg
+	case "tier":
+		e.Tier = arg
+	case "pattern":
+		e.AllowedPattern = arg
+	case "tag":
+		kv := strings.SplitN(arg, "=", 2)
+		if len(kv) != 2 || kv[0] == "" {
+			return ErrInvalidAnnotation{Annotation: line}
+		}
+		if e.Tags == nil {
+			e.Tags = make(map[string]string)
+		}
+		e.Tags[kv[0]] = kv[1]
+	default:
+		return ErrInvalidAnnotation{Annotation: line}
+	}
+	return nil
+}
+
+// splitTypeMark removes the type mark from the left hand side of an expression,
+// and returns the type and the KMS key ID of "name@key".
+func splitTypeMark(lhs string) (string, string, string) {
+	switch {
+	case strings.Contains(lhs, secureMark):
+		i := strings.Index(lhs, secureMark)
+		return lhs[:i], ssm.ParameterTypeSecureString, lhs[i+len(secureMark):]
+	case strings.HasSuffix(lhs, listMark):
+		return strings.TrimSuffix(lhs, listMark), ssm.ParameterTypeStringList, ""
+	default:
+		return lhs, ssm.ParameterTypeString, ""
+	}
+}
+
+// typeMark returns the mark of the type with the KMS key ID if any.
+func typeMark(_type string, keyID string) string {
+	switch _type {
+	case ssm.ParameterTypeSecureString:
+		return secureMark + keyID
+	case ssm.ParameterTypeStringList:
+		return listMark
+	default:
+		return ""
+	}
+}
+
 func newExpression(p *ssm.Parameter) *expression {
 	return &expression{
-		Name:   *p.Name,
-		Value:  *p.Value,
-		Secure: *p.Type == ssm.ParameterTypeSecureString,
+		Name:  *p.Name,
+		Value: *p.Value,
+		Type:  *p.Type,
 	}
 }
 
@@ -56,56 +190,54 @@ func (e *expression) serialize(path string) (string, error) {
 	if err != nil {
 		return "", err
 	}
-	return buildExpr("", lhs, e.Value, e.Secure)
+	return buildExpr("", lhs, e.Value, typeMark(e.Type, e.KeyID))
 }
 
 func (e *expression) env() (string, error) {
 	lhs := gopath.Base(e.Name)
-	return buildExpr("", lhs, e.Value, false)
+	return buildExpr("", lhs, e.Value, "")
 }
 
 func (e *expression) export() (string, error) {
 	lhs := exportableName(e.Name)
-	return buildExpr("export ", lhs, e.Value, false)
+	return buildExpr("export ", lhs, e.Value, "")
 }
 
 func (e *expression) log() (string, error) {
-	value := e.Value
-	if e.Secure {
-		value = strings.Repeat("*", 16)
+	return buildExpr("", abs(e.Name), e.maskedValue(), typeMark(e.Type, e.KeyID))
+}
+
+func (e *expression) maskedValue() string {
+	if e.Type == ssm.ParameterTypeSecureString {
+		return mask
 	}
-	return buildExpr("", abs(e.Name), value, e.Secure)
+	return e.Value
 }
 
-func (e *expression) parameter(path string) (*ssm.Parameter, error) {
+func (e *expression) parameter(path string) (*fullParameter, error) {
 	name, err := join(path, e.Name)
 	if err != nil {
 		return nil, err
 	}
 
-	_type := ssm.ParameterTypeString
-	if e.Secure {
-		_type = ssm.ParameterTypeSecureString
-	}
-
-	return &ssm.Parameter{
-		Name:  &name,
-		Type:  &_type,
-		Value: &e.Value,
+	return &fullParameter{
+		Name:           name,
+		Type:           e.Type,
+		Value:          e.Value,
+		KeyID:          e.KeyID,
+		Description:    e.Description,
+		Tier:           e.Tier,
+		Tags:           e.Tags,
+		AllowedPattern: e.AllowedPattern,
 	}, nil
 }
 
-func buildExpr(prefix, lhs, value string, secure bool) (string, error) {
+func buildExpr(prefix, lhs, value, mark string) (string, error) {
 	rhs, err := escape(value)
 	if err != nil {
 		return "", err
 	}
 
-	mark := ""
-	if secure {
-		mark = secureMark
-	}
-
 	return fmt.Sprintf("%s%s%s=%s", prefix, lhs, mark, rhs), nil
 }
 
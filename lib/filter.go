@@ -0,0 +1,136 @@
+package lib
+
+import (
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ssm"
+)
+
+// A Filter selects parameters by their metadata.
+// Tags, Type and Tier are filtered by DescribeParameters, and the others client-side.
+type Filter struct {
+	// Tags are the tags which parameters must have.
+	Tags map[string]string
+
+	// Type and Tier are the type and the tier which parameters must have.
+	Type string
+	Tier string
+
+	// Label selects the labeled versions of parameters. Parameters without the label are omitted
+	// because GetParameters returns them as invalid, as a path with a label selector does.
+	Label string
+
+	// ModifiedSince omits parameters last modified before it.
+	ModifiedSince time.Time
+
+	// NameContains omits parameters whose names relative to the path do not contain it.
+	NameContains string
+}
+
+// ParseTags parses key=value pairs.
+func ParseTags(pairs []string) (map[string]string, error) {
+	if len(pairs) == 0 {
+		return nil, nil
+	}
+	tags := make(map[string]string)
+	for _, pair := range pairs {
+		kv := strings.SplitN(pair, "=", 2)
+		if len(kv) != 2 || kv[0] == "" {
+			return nil, ErrInvalidTag{Tag: pair}
+		}
+		tags[kv[0]] = kv[1]
+	}
+	return tags, nil
+}
+
+// Empty reports whether the filter selects all parameters.
+func (f Filter) Empty() bool {
+	return len(f.Tags) == 0 && f.Type == "" && f.Tier == "" && f.Label == "" &&
+		f.ModifiedSince.IsZero() && f.NameContains == ""
+}
+
+// parameterFilters returns the filters of DescribeParameters.
+func (f Filter) parameterFilters() []*ssm.ParameterStringFilter {
+	keys := make([]string, 0, len(f.Tags))
+	for key := range f.Tags {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var filters []*ssm.ParameterStringFilter
+	for _, key := range keys {
+		filters = append(filters, &ssm.ParameterStringFilter{
+			Key:    aws.String("tag:" + key),
+			Values: []*string{aws.String(f.Tags[key])},
+		})
+	}
+	if f.Type != "" {
+		filters = append(filters, &ssm.ParameterStringFilter{Key: aws.String("Type"), Values: []*string{&f.Type}})
+	}
+	if f.Tier != "" {
+		filters = append(filters, &ssm.ParameterStringFilter{Key: aws.String("Tier"), Values: []*string{&f.Tier}})
+	}
+	return filters
+}
+
+// match reports whether the parameter of the path matches the filters which DescribeParameters does not support.
+func (f Filter) match(meta *ssm.ParameterMetadata, path string) bool {
+	if !f.ModifiedSince.IsZero() && aws.TimeValue(meta.LastModifiedDate).Before(f.ModifiedSince) {
+		return false
+	}
+	name, err := rel(*meta.Name, path)
+	if err != nil {
+		name = abs(*meta.Name)
+	}
+	return strings.Contains(name, f.NameContains)
+}
+
+// getFilteredParameters returns the parameters of the path matching the filter.
+// The path may end with a version or label selector, which can not be given with filter.Label.
+// SecureString values are left encrypted unless decrypt.
+func getFilteredParameters(
+	svc *ssm.SSM,
+	path string,
+	recursive bool,
+	filter Filter,
+	decrypt bool,
+) ([]*ssm.Parameter, error) {
+	if filter.Empty() {
+		return getParametersByPath(svc, path, recursive, decrypt)
+	}
+
+	path, selector := splitSelector(path)
+	if selector != "" {
+		if filter.Label != "" {
+			return nil, ErrLabelWithSelector
+		}
+		filter.Label = selector
+	}
+
+	metas, err := describeParameters(svc, []string{path}, recursive, filter.parameterFilters()...)
+	if err != nil {
+		return nil, err
+	}
+
+	var names []*string
+	for _, meta := range metas {
+		if !filter.match(meta, path) {
+			continue
+		}
+		name := *meta.Name
+		if filter.Label != "" {
+			name += ":" + filter.Label
+		}
+		names = append(names, aws.String(name))
+	}
+
+	params, err := getParametersByNames(svc, names, decrypt)
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(params, func(i, j int) bool { return *params[i].Name < *params[j].Name })
+	return params, nil
+}
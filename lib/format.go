@@ -0,0 +1,481 @@
+package lib
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	gopath "path"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/aws/aws-sdk-go/service/ssm"
+	yaml "gopkg.in/yaml.v2"
+)
+
+// Formats of parameter files.
+const (
+	FormatDotenv     = "dotenv"
+	FormatJSON       = "json"
+	FormatYAML       = "yaml"
+	FormatProperties = "properties"
+)
+
+// A SecureMatcher decides which keys of a parameter file are SecureString.
+type SecureMatcher struct {
+	Keys     []string
+	Suffixes []string
+}
+
+func (m SecureMatcher) match(key string) bool {
+	base := gopath.Base(key)
+	for _, k := range m.Keys {
+		if k == key || k == base {
+			return true
+		}
+	}
+	for _, suffix := range m.Suffixes {
+		if strings.HasSuffix(base, suffix) {
+			return true
+		}
+	}
+	return false
+}
+
+type keyValue struct {
+	Key   string
+	Value string
+	Type  string // empty if not given by the file
+	KeyID string
+
+	Annotations []string // only in dotenv
+}
+
+// FormatFromFilename guesses the format of a parameter file from its extension.
+func FormatFromFilename(filename string) string {
+	switch strings.ToLower(gopath.Ext(filename)) {
+	case ".json":
+		return FormatJSON
+	case ".yml", ".yaml":
+		return FormatYAML
+	case ".properties":
+		return FormatProperties
+	default:
+		return FormatDotenv
+	}
+}
+
+// ParseFile parses a parameter file and returns expressions relative to a path.
+// Nested objects of JSON and YAML are mapped to sub-paths.
+// nolint: gocyclo
+func ParseFile(r io.Reader, format string, secure SecureMatcher) ([]string, error) {
+	var kvs []keyValue
+	var err error
+	switch format {
+	case FormatDotenv:
+		kvs, err = parseDotenv(r)
+	case FormatJSON:
+		kvs, err = parseJSON(r)
+	case FormatYAML:
+		kvs, err = parseYAML(r)
+	case FormatProperties:
+		kvs, err = parseProperties(r)
+	default:
+		return nil, ErrUnknownFormat{Format: format}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	exprs := make([]string, 0, len(kvs))
+	for _, kv := range kvs {
+		if !isRel(kv.Key) {
+			return nil, ErrInvalidName{Name: kv.Key}
+		}
+		_type := kv.Type
+		if _type == "" {
+			_type = ssm.ParameterTypeString
+			if secure.match(kv.Key) {
+				_type = ssm.ParameterTypeSecureString
+			}
+		}
+		expr, err := buildExpr("", kv.Key, kv.Value, typeMark(_type, kv.KeyID))
+		if err != nil {
+			return nil, err
+		}
+		exprs = append(append(exprs, kv.Annotations...), expr)
+	}
+	return exprs, nil
+}
+
+// nolint: gocyclo
+func parseDotenv(r io.Reader) ([]keyValue, error) {
+	var kvs []keyValue
+	var annotations []string
+	scanner := bufio.NewScanner(r)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if IsAnnotation(line) {
+			annotations = append(annotations, line)
+			continue
+		}
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		line = strings.TrimPrefix(line, "export ")
+
+		sides := strings.SplitN(line, "=", 2)
+		if len(sides) != 2 {
+			return nil, ErrInvalidLine{Format: FormatDotenv, Line: n, Text: scanner.Text()}
+		}
+		key := strings.TrimSpace(sides[0])
+		var _type, keyID string
+		if strings.Contains(key, secureMark) || strings.HasSuffix(key, listMark) {
+			key, _type, keyID = splitTypeMark(key)
+		}
+		value, ok := dotenvValue(strings.TrimSpace(sides[1]))
+		if key == "" || !ok {
+			return nil, ErrInvalidLine{Format: FormatDotenv, Line: n, Text: scanner.Text()}
+		}
+		kvs = append(kvs, keyValue{Key: key, Value: value, Type: _type, KeyID: keyID, Annotations: annotations})
+		annotations = nil
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(annotations) > 0 {
+		return nil, ErrDanglingAnnotation{Annotation: annotations[len(annotations)-1]}
+	}
+	return kvs, nil
+}
+
+func dotenvValue(rhs string) (string, bool) {
+	if rhs == "" {
+		return "", true
+	}
+
+	switch rhs[0] {
+	case '\'':
+		end := strings.IndexByte(rhs[1:], '\'')
+		if end < 0 {
+			return "", false
+		}
+		return rhs[1 : end+1], true
+	case '"':
+		return unquoteDotenv(rhs)
+	default:
+		if i := strings.Index(rhs, " #"); i >= 0 {
+			rhs = rhs[:i]
+		}
+		return strings.TrimSpace(rhs), true
+	}
+}
+
+// dotenvEscapes are the escape sequences in double quotes of dotenv.
+var dotenvEscapes = map[byte]byte{'n': '\n', 'r': '\r', 't': '\t'}
+
+// unquoteDotenv returns the value in the double quotes at the beginning of rhs, or false if they are not closed.
+func unquoteDotenv(rhs string) (string, bool) {
+	var buf bytes.Buffer
+	for i := 1; i < len(rhs); i++ {
+		c := rhs[i]
+		switch {
+		case c == '"':
+			return buf.String(), true
+		case c == '\\' && i+1 < len(rhs):
+			i++
+			if escaped, ok := dotenvEscapes[rhs[i]]; ok {
+				buf.WriteByte(escaped)
+			} else {
+				buf.WriteByte(rhs[i])
+			}
+		default:
+			buf.WriteByte(c)
+		}
+	}
+	return "", false
+}
+
+func parseJSON(r io.Reader) ([]keyValue, error) {
+	decoder := json.NewDecoder(r)
+	decoder.UseNumber()
+	var root interface{}
+	if err := decoder.Decode(&root); err != nil {
+		return nil, err
+	}
+	return flatten(nil, "", root)
+}
+
+func parseYAML(r io.Reader) ([]keyValue, error) {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	var root yaml.MapSlice
+	if err := yaml.Unmarshal(data, &root); err != nil {
+		return nil, err
+	}
+	return flatten(nil, "", root)
+}
+
+// nolint: gocyclo
+func flatten(kvs []keyValue, key string, value interface{}) ([]keyValue, error) {
+	var err error
+	switch v := value.(type) {
+	case map[string]interface{}:
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			if kvs, err = flatten(kvs, joinKey(key, k), v[k]); err != nil {
+				return nil, err
+			}
+		}
+	case yaml.MapSlice:
+		for _, item := range v {
+			if kvs, err = flatten(kvs, joinKey(key, fmt.Sprint(item.Key)), item.Value); err != nil {
+				return nil, err
+			}
+		}
+	case map[interface{}]interface{}:
+		keys := make([]string, 0, len(v))
+		values := make(map[string]interface{}, len(v))
+		for k, vv := range v {
+			keys = append(keys, fmt.Sprint(k))
+			values[fmt.Sprint(k)] = vv
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			if kvs, err = flatten(kvs, joinKey(key, k), values[k]); err != nil {
+				return nil, err
+			}
+		}
+	case []interface{}:
+		list, ok := listValue(v)
+		if key == "" || !ok {
+			return nil, ErrUnsupportedValue{Key: key, Value: fmt.Sprint(v)}
+		}
+		kvs = append(kvs, keyValue{Key: key, Value: list, Type: ssm.ParameterTypeStringList})
+	case nil:
+		if key == "" {
+			return kvs, nil
+		}
+		kvs = append(kvs, keyValue{Key: key, Value: ""})
+	default:
+		if key == "" {
+			return nil, ErrUnsupportedValue{Key: key, Value: fmt.Sprint(v)}
+		}
+		kvs = append(kvs, keyValue{Key: key, Value: fmt.Sprint(v)})
+	}
+	return kvs, nil
+}
+
+// listValue joins the items of an array as the value of a StringList.
+// It returns false unless all the items are scalars without commas.
+func listValue(items []interface{}) (string, bool) {
+	values := make([]string, len(items))
+	for i, item := range items {
+		switch item.(type) {
+		case []interface{}, map[string]interface{}, map[interface{}]interface{}, yaml.MapSlice, nil:
+			return "", false
+		}
+		values[i] = fmt.Sprint(item)
+		if strings.Contains(values[i], ",") {
+			return "", false
+		}
+	}
+	return strings.Join(values, ","), true
+}
+
+// A parameterMetadata is a value with its metadata in structured output.
+type parameterMetadata struct {
+	Value interface{} `yaml:"Value"`
+	Type  string      `yaml:"Type"`
+	Tier  string      `yaml:"Tier"`
+	Size  int         `yaml:"Size,omitempty" json:",omitempty"`
+}
+
+// writeParameters writes parameters as an object keyed by names relative to a path.
+// The values of StringList parameters are arrays.
+// Each value is written with its metadata if tiers, which are keyed by names, are given.
+// SecureString values are masked unless revealed, and their sizes are omitted unless decrypted.
+func writeParameters(
+	w io.Writer,
+	params []*ssm.Parameter,
+	path string,
+	tiers map[string]string,
+	getOpts GetOptions,
+) error {
+	sort.Slice(params, func(i, j int) bool { return *params[i].Name < *params[j].Name })
+
+	var object yaml.MapSlice
+	for _, param := range params {
+		name, err := rel(*param.Name, path)
+		if err != nil {
+			return err
+		}
+		var value interface{} = getOpts.value(param)
+		if *param.Type == ssm.ParameterTypeStringList {
+			value = strings.Split(*param.Value, ",")
+		}
+		if tiers != nil {
+			meta := parameterMetadata{Value: value, Type: *param.Type, Tier: tiers[*param.Name], Size: len(*param.Value)}
+			if getOpts.NoDecrypt && *param.Type == ssm.ParameterTypeSecureString {
+				meta.Size = 0
+			}
+			value = meta
+		}
+		object = append(object, yaml.MapItem{Key: name, Value: value})
+	}
+
+	if getOpts.Format == FormatYAML {
+		data, err := yaml.Marshal(object)
+		if err != nil {
+			return err
+		}
+		_, err = w.Write(data)
+		return err
+	}
+
+	values := make(map[string]interface{}, len(object))
+	for _, item := range object {
+		values[item.Key.(string)] = item.Value
+	}
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(values)
+}
+
+func joinKey(prefix, key string) string {
+	if prefix == "" {
+		return key
+	}
+	return prefix + "/" + key
+}
+
+func parseProperties(r io.Reader) ([]keyValue, error) {
+	var kvs []keyValue
+	scanner := bufio.NewScanner(r)
+	logical := ""
+	n := 0
+	for scanner.Scan() {
+		n++
+		line := strings.TrimLeftFunc(scanner.Text(), unicode.IsSpace)
+		if logical == "" && (line == "" || line[0] == '#' || line[0] == '!') {
+			continue
+		}
+
+		if continued(line) {
+			logical += line[:len(line)-1]
+			continue
+		}
+		logical += line
+
+		var err error
+		if kvs, err = appendProperty(kvs, logical, n); err != nil {
+			return nil, err
+		}
+		logical = ""
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if logical != "" {
+		return appendProperty(kvs, logical, n)
+	}
+	return kvs, nil
+}
+
+// appendProperty appends the property of the logical line which ends at the line n.
+func appendProperty(kvs []keyValue, logical string, n int) ([]keyValue, error) {
+	key, value, err := splitProperty(logical)
+	if err != nil {
+		return nil, ErrInvalidLine{Format: FormatProperties, Line: n, Text: logical}
+	}
+	return append(kvs, keyValue{Key: key, Value: value}), nil
+}
+
+// continued reports whether a line ends with an odd number of backslashes.
+func continued(line string) bool {
+	n := 0
+	for i := len(line) - 1; i >= 0 && line[i] == '\\'; i-- {
+		n++
+	}
+	return n%2 == 1
+}
+
+// propertyKeyEnd returns the index of the separator or the whitespace after the key of a line.
+func propertyKeyEnd(line string) int {
+	for i := 0; i < len(line); i++ {
+		if line[i] == '\\' {
+			i++
+			continue
+		}
+		if strings.IndexByte("=: \t\f", line[i]) >= 0 {
+			return i
+		}
+	}
+	return len(line)
+}
+
+func splitProperty(line string) (string, string, error) {
+	end := propertyKeyEnd(line)
+
+	key, err := unescapeProperty(line[:end])
+	if err != nil {
+		return "", "", err
+	}
+
+	rest := strings.TrimLeft(line[end:], " \t\f")
+	if rest != "" && (rest[0] == '=' || rest[0] == ':') {
+		rest = strings.TrimLeft(rest[1:], " \t\f")
+	}
+
+	value, err := unescapeProperty(rest)
+	if err != nil {
+		return "", "", err
+	}
+	return key, value, nil
+}
+
+// propertyEscapes are the escape sequences of properties other than \uXXXX.
+var propertyEscapes = map[byte]byte{'t': '\t', 'n': '\n', 'r': '\r', 'f': '\f'}
+
+func unescapeProperty(s string) (string, error) {
+	if !strings.Contains(s, `\`) {
+		return s, nil
+	}
+
+	var buf bytes.Buffer
+	for i := 0; i < len(s); i++ {
+		if s[i] != '\\' || i+1 == len(s) {
+			buf.WriteByte(s[i])
+			continue
+		}
+		i++
+		if escaped, ok := propertyEscapes[s[i]]; ok {
+			buf.WriteByte(escaped)
+			continue
+		}
+		if s[i] != 'u' {
+			buf.WriteByte(s[i])
+			continue
+		}
+		if i+5 > len(s) {
+			return "", strconv.ErrSyntax
+		}
+		code, err := strconv.ParseUint(s[i+1:i+5], 16, 16)
+		if err != nil {
+			return "", err
+		}
+		buf.WriteRune(rune(code))
+		i += 4
+	}
+	return buf.String(), nil
+}
@@ -0,0 +1,113 @@
+package lib
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ssm"
+)
+
+// A historyEntry is a version of a parameter.
+type historyEntry struct {
+	Version          int64
+	LastModifiedDate time.Time
+	LastModifiedUser string
+	Type             string
+	Labels           []string
+	Value            string
+	Description      string `json:",omitempty"`
+}
+
+func newHistoryEntry(h *ssm.ParameterHistory, showValues bool) historyEntry {
+	value := aws.StringValue(h.Value)
+	if !showValues {
+		value = (&expression{Value: value, Type: aws.StringValue(h.Type)}).maskedValue()
+	}
+	return historyEntry{
+		Version:          aws.Int64Value(h.Version),
+		LastModifiedDate: aws.TimeValue(h.LastModifiedDate).UTC(),
+		LastModifiedUser: aws.StringValue(h.LastModifiedUser),
+		Type:             aws.StringValue(h.Type),
+		Labels:           append([]string{}, aws.StringValueSlice(h.Labels)...),
+		Value:            value,
+		Description:      aws.StringValue(h.Description),
+	}
+}
+
+// getParameterHistory returns all the versions of the parameter from the oldest.
+func getParameterHistory(svc *ssm.SSM, name string) ([]*ssm.ParameterHistory, error) {
+	if err := validateName(name); err != nil {
+		return nil, err
+	}
+
+	input := ssm.GetParameterHistoryInput{
+		Name:           &name,
+		MaxResults:     aws.Int64(50),
+		WithDecryption: aws.Bool(true),
+	}
+	var history []*ssm.ParameterHistory
+	fn := func(output *ssm.GetParameterHistoryOutput, _ bool) bool {
+		history = append(history, output.Parameters...)
+		return true
+	}
+	if err := svc.GetParameterHistoryPages(&input, fn); err != nil {
+		return nil, err
+	}
+	return history, nil
+}
+
+// History is the implementation of `ssmenv history`.
+// SecureString values are masked unless showValues.
+func History(w io.Writer, svc *ssm.SSM, path string, name string, showValues bool, format string) error {
+	name, err := join(path, name)
+	if err != nil {
+		return err
+	}
+
+	history, err := getParameterHistory(svc, name)
+	if err != nil {
+		return err
+	}
+
+	entries := make([]historyEntry, len(history))
+	for i, h := range history {
+		entries[i] = newHistoryEntry(h, showValues)
+	}
+
+	switch format {
+	case FormatJSON:
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(entries)
+	case FormatText:
+	default:
+		return ErrUnknownFormat{Format: format}
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "VERSION\tDATE\tUSER\tTYPE\tLABELS\tVALUE")
+	for _, entry := range entries {
+		value, err := escape(entry.Value)
+		if err != nil {
+			return err
+		}
+		labels := strings.Join(entry.Labels, ",")
+		if labels == "" {
+			labels = "-"
+		}
+		fmt.Fprintf(tw, "%d\t%s\t%s\t%s\t%s\t%s\n",
+			entry.Version,
+			entry.LastModifiedDate.Format(time.RFC3339),
+			entry.LastModifiedUser,
+			entry.Type,
+			labels,
+			value,
+		)
+	}
+	return tw.Flush()
+}
@@ -0,0 +1,138 @@
+package lib
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ssm"
+)
+
+// splitVersion splits "name:version" into the name and the version, which is 0 if not given.
+func splitVersion(path string, name string) (string, int64, error) {
+	name, err := joinWithSelector(path, name)
+	if err != nil {
+		return "", 0, err
+	}
+	base, selector := splitSelector(name)
+	if selector == "" {
+		return base, 0, nil
+	}
+	if !reVersion.MatchString(selector) {
+		return "", 0, ErrInvalidName{Name: name}
+	}
+	var version int64
+	if _, err := fmt.Sscan(selector, &version); err != nil {
+		return "", 0, err
+	}
+	return base, version, nil
+}
+
+// Label is the implementation of `ssmenv label`.
+// The latest version is labeled if the name does not have a version.
+func Label(w io.Writer, svc *ssm.SSM, path string, name string, labels []string) error {
+	if len(labels) == 0 {
+		return ErrRequireNameAndLabel
+	}
+
+	name, version, err := splitVersion(path, name)
+	if err != nil {
+		return err
+	}
+
+	input := &ssm.LabelParameterVersionInput{
+		Name:   &name,
+		Labels: aws.StringSlice(labels),
+	}
+	if version > 0 {
+		input.ParameterVersion = &version
+	}
+	output, err := svc.LabelParameterVersion(input)
+	if err != nil {
+		return err
+	}
+	if len(output.InvalidLabels) > 0 {
+		return ErrInvalidLabels{Labels: aws.StringValueSlice(output.InvalidLabels)}
+	}
+
+	fmt.Fprintf(w, "LABEL %s:%d %s\n", abs(name), aws.Int64Value(output.ParameterVersion), strings.Join(labels, " "))
+	return nil
+}
+
+// Unlabel is the implementation of `ssmenv unlabel`.
+// The labels are removed from whichever versions have them if the name does not have a version.
+func Unlabel(w io.Writer, svc *ssm.SSM, path string, name string, labels []string) error {
+	if len(labels) == 0 {
+		return ErrRequireNameAndLabel
+	}
+
+	name, version, err := splitVersion(path, name)
+	if err != nil {
+		return err
+	}
+
+	labelsByVersion := map[int64][]string{version: labels}
+	versions := []int64{version}
+	if version == 0 {
+		history, err := getParameterHistory(svc, name)
+		if err != nil {
+			return err
+		}
+		if labelsByVersion, versions, err = findLabels(history, labels); err != nil {
+			return err
+		}
+	}
+
+	for _, v := range versions {
+		v := v
+		output, err := svc.UnlabelParameterVersion(&ssm.UnlabelParameterVersionInput{
+			Name:             &name,
+			ParameterVersion: &v,
+			Labels:           aws.StringSlice(labelsByVersion[v]),
+		})
+		if err != nil {
+			return err
+		}
+		if len(output.InvalidLabels) > 0 {
+			return ErrInvalidLabels{Labels: aws.StringValueSlice(output.InvalidLabels)}
+		}
+		fmt.Fprintf(w, "UNLABEL %s:%d %s\n", abs(name), v, strings.Join(aws.StringValueSlice(output.RemovedLabels), " "))
+	}
+	return nil
+}
+
+// findLabels returns the versions which have the labels, and the labels of each version.
+func findLabels(history []*ssm.ParameterHistory, labels []string) (map[int64][]string, []int64, error) {
+	unknown := make(map[string]bool)
+	for _, label := range labels {
+		unknown[label] = true
+	}
+
+	labelsByVersion := make(map[int64][]string)
+	var versions []int64
+	for _, h := range history {
+		version := aws.Int64Value(h.Version)
+		for _, label := range aws.StringValueSlice(h.Labels) {
+			if !unknown[label] {
+				continue
+			}
+			delete(unknown, label)
+			if labelsByVersion[version] == nil {
+				versions = append(versions, version)
+			}
+			labelsByVersion[version] = append(labelsByVersion[version], label)
+		}
+	}
+
+	var invalid []string
+	for _, label := range labels {
+		if unknown[label] {
+			invalid = append(invalid, label)
+		}
+	}
+	if len(invalid) > 0 {
+		return nil, nil, ErrInvalidLabels{Labels: invalid}
+	}
+	return labelsByVersion, versions, nil
+}
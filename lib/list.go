@@ -0,0 +1,104 @@
+package lib
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ssm"
+)
+
+// A listNode is a node of the tree of a hierarchy.
+// It is a parameter, a path which has children, or both.
+type listNode struct {
+	meta     *ssm.ParameterMetadata
+	children map[string]*listNode
+}
+
+func (n *listNode) child(name string) *listNode {
+	if n.children == nil {
+		n.children = make(map[string]*listNode)
+	}
+	child, ok := n.children[name]
+	if !ok {
+		child = &listNode{}
+		n.children[name] = child
+	}
+	return child
+}
+
+// write writes the children of the node down to the depth, which is unlimited if not positive.
+func (n *listNode) write(w io.Writer, indent string, depth int) {
+	names := make([]string, 0, len(n.children))
+	for name := range n.children {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for i, name := range names {
+		child := n.children[name]
+		branch, next := "├── ", "│   "
+		if i == len(names)-1 {
+			branch, next = "└── ", "    "
+		}
+
+		if child.meta == nil {
+			fmt.Fprintf(w, "%s%s/\t\t\t\t\n", indent, branch+name)
+		} else {
+			fmt.Fprintf(
+				w,
+				"%s%s\t%s\t%d\t%s\t%s\n",
+				indent,
+				branch+name,
+				aws.StringValue(child.meta.Type),
+				aws.Int64Value(child.meta.Version),
+				aws.TimeValue(child.meta.LastModifiedDate).UTC().Format(time.RFC3339),
+				aws.StringValue(child.meta.Tier),
+			)
+		}
+
+		if depth != 1 {
+			child.write(w, indent+next, depth-1)
+		}
+	}
+}
+
+// List is the implementation of `ssmenv ls`.
+// It prints the parameters of the path as a tree only with DescribeParameters, so values are never retrieved.
+// Parameters deeper than depth are omitted if depth is positive.
+func List(w io.Writer, svc *ssm.SSM, path string, depth int, filter Filter) error {
+	if path == "" {
+		path = "/"
+	}
+
+	metas, err := describeParameters(svc, []string{path}, true, filter.parameterFilters()...)
+	if err != nil {
+		return err
+	}
+
+	root := &listNode{}
+	for _, meta := range metas {
+		if !filter.match(meta, path) {
+			continue
+		}
+		name, relErr := rel(*meta.Name, path)
+		if relErr != nil {
+			return relErr
+		}
+		node := root
+		for _, elem := range strings.Split(name, "/") {
+			node = node.child(elem)
+		}
+		node.meta = meta
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "NAME\tTYPE\tVERSION\tLAST MODIFIED\tTIER")
+	fmt.Fprintf(tw, "%s\t\t\t\t\n", path)
+	root.write(tw, "", depth)
+	return tw.Flush()
+}
@@ -0,0 +1,229 @@
+package lib
+
+import (
+	"reflect"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ssm"
+	"github.com/m4i/ssmenv/semaphore"
+)
+
+// defaultKeyID is the AWS managed KMS key used if no key is given.
+const defaultKeyID = "alias/aws/ssm"
+
+// Max sizes of values in bytes by tier.
+const (
+	maxStandardValueSize = 4 * 1024
+	maxAdvancedValueSize = 8 * 1024
+)
+
+// TierAuto is the tier option which puts values too large for Standard in Intelligent-Tiering.
+const TierAuto = "auto"
+
+// maxValueSize returns the max size of a value in the tier.
+func maxValueSize(tier string) int {
+	switch tier {
+	case ssm.ParameterTierAdvanced, ssm.ParameterTierIntelligentTiering:
+		return maxAdvancedValueSize
+	default:
+		return maxStandardValueSize
+	}
+}
+
+// A fullParameter is a parameter with its metadata and tags.
+type fullParameter struct {
+	Name           string
+	Type           string
+	Value          string
+	Description    string            `json:",omitempty"`
+	KeyID          string            `json:"KeyId,omitempty"`
+	Tier           string            `json:",omitempty"`
+	Tags           map[string]string `json:",omitempty"`
+	Policies       []string          `json:",omitempty"`
+	AllowedPattern string            `json:",omitempty"`
+	Version        int64             `json:"-"`
+}
+
+func newFullParameter(p *ssm.Parameter) *fullParameter {
+	return &fullParameter{
+		Name:    *p.Name,
+		Type:    *p.Type,
+		Value:   *p.Value,
+		Version: aws.Int64Value(p.Version),
+	}
+}
+
+func (p *fullParameter) expression() *expression {
+	return &expression{
+		Name:  p.Name,
+		Value: p.Value,
+		Type:  p.Type,
+		KeyID: p.KeyID,
+	}
+}
+
+// differs reports whether the parameter must be put over the old one.
+// KMS keys are compared only if both are known.
+func (p *fullParameter) differs(old *fullParameter) bool {
+	if p.Type != old.Type || p.Value != old.Value {
+		return true
+	}
+	return p.KeyID != "" && old.KeyID != "" && p.KeyID != old.KeyID
+}
+
+// metadataDiffers reports whether the given description, tier, tags or allowed pattern differ from the old ones,
+// or the old parameter lacks any type of the given policies.
+// Intelligent-Tiering is never a difference because it is resolved to Standard or Advanced.
+func (p *fullParameter) metadataDiffers(old *fullParameter) bool {
+	if p.Description != "" && p.Description != old.Description {
+		return true
+	}
+	if p.Tier != "" && p.Tier != ssm.ParameterTierIntelligentTiering && p.Tier != old.Tier {
+		return true
+	}
+	if p.AllowedPattern != "" && p.AllowedPattern != old.AllowedPattern {
+		return true
+	}
+	if !hasPolicyTypes(old.Policies, policyTypes(p.Policies)) {
+		return true
+	}
+	return p.Tags != nil && !reflect.DeepEqual(p.Tags, old.Tags)
+}
+
+// getFullParameters returns the parameters of the given paths with their metadata and tags.
+func getFullParameters(svc *ssm.SSM, paths []string, recursive bool) ([]*fullParameter, error) {
+	metas, err := describeParameters(svc, paths, recursive)
+	if err != nil {
+		return nil, err
+	}
+	return fullParametersOf(svc, metas)
+}
+
+// fullParametersOf returns the described parameters with their values and tags.
+func fullParametersOf(svc *ssm.SSM, metas []*ssm.ParameterMetadata) ([]*fullParameter, error) {
+	names := make([]*string, len(metas))
+	for i, meta := range metas {
+		names[i] = meta.Name
+	}
+	params, err := GetParametersByNames(svc, names)
+	if err != nil {
+		return nil, err
+	}
+	valuesByName := make(map[string]string)
+	for _, param := range params {
+		valuesByName[*param.Name] = *param.Value
+	}
+
+	fulls := make([]*fullParameter, len(metas))
+	sem := semaphore.New(MaxConnection)
+	for i, meta := range metas {
+		full := &fullParameter{
+			Name:           *meta.Name,
+			Type:           *meta.Type,
+			Value:          valuesByName[*meta.Name],
+			Description:    aws.StringValue(meta.Description),
+			Tier:           aws.StringValue(meta.Tier),
+			AllowedPattern: aws.StringValue(meta.AllowedPattern),
+		}
+		if *meta.Type == ssm.ParameterTypeSecureString {
+			full.KeyID = aws.StringValue(meta.KeyId)
+		}
+		for _, policy := range meta.Policies {
+			full.Policies = append(full.Policies, aws.StringValue(policy.PolicyText))
+		}
+		fulls[i] = full
+
+		sem.Go(func() error {
+			tags, err := listTags(svc, full.Name)
+			if err != nil {
+				return err
+			}
+			full.Tags = tags
+			return nil
+		})
+	}
+	if err := sem.Wait(); err != nil {
+		return nil, err
+	}
+	return fulls, nil
+}
+
+func listTags(svc *ssm.SSM, name string) (map[string]string, error) {
+	output, err := svc.ListTagsForResource(&ssm.ListTagsForResourceInput{
+		ResourceType: aws.String(ssm.ResourceTypeForTaggingParameter),
+		ResourceId:   &name,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(output.TagList) == 0 {
+		return nil, nil
+	}
+	tags := make(map[string]string)
+	for _, tag := range output.TagList {
+		tags[*tag.Key] = *tag.Value
+	}
+	return tags, nil
+}
+
+// removeTags removes the tags which are not in keep.
+func removeTags(svc *ssm.SSM, name string, tags map[string]string, keep map[string]string) error {
+	var keys []*string
+	for key := range tags {
+		if _, ok := keep[key]; !ok {
+			keys = append(keys, aws.String(key))
+		}
+	}
+	if len(keys) == 0 {
+		return nil
+	}
+	_, err := svc.RemoveTagsFromResource(&ssm.RemoveTagsFromResourceInput{
+		ResourceType: aws.String(ssm.ResourceTypeForTaggingParameter),
+		ResourceId:   aws.String(name),
+		TagKeys:      keys,
+	})
+	return err
+}
+
+func putFullParameter(svc *ssm.SSM, full *fullParameter) error {
+	input := &ssm.PutParameterInput{
+		Name:      aws.String(full.Name),
+		Type:      aws.String(full.Type),
+		Value:     aws.String(full.Value),
+		Overwrite: aws.Bool(true),
+	}
+	if full.Description != "" {
+		input.Description = aws.String(full.Description)
+	}
+	if full.KeyID != "" {
+		input.KeyId = aws.String(full.KeyID)
+	}
+	if full.Tier != "" {
+		input.Tier = aws.String(full.Tier)
+	}
+	if len(full.Policies) > 0 {
+		input.Policies = aws.String("[" + strings.Join(full.Policies, ",") + "]")
+	}
+	if full.AllowedPattern != "" {
+		input.AllowedPattern = aws.String(full.AllowedPattern)
+	}
+	if _, err := svc.PutParameter(input); err != nil {
+		return err
+	}
+
+	// Tags can not be given to PutParameter with Overwrite.
+	if len(full.Tags) == 0 {
+		return nil
+	}
+	var tags []*ssm.Tag
+	for key, value := range full.Tags {
+		tags = append(tags, &ssm.Tag{Key: aws.String(key), Value: aws.String(value)})
+	}
+	_, err := svc.AddTagsToResource(&ssm.AddTagsToResourceInput{
+		ResourceType: aws.String(ssm.ResourceTypeForTaggingParameter),
+		ResourceId:   aws.String(full.Name),
+		Tags:         tags,
+	})
+	return err
+}
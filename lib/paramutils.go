@@ -12,6 +12,8 @@ var (
 	reBase     = regexp.MustCompile(`^[-.\w]+$`)
 	reRel      = regexp.MustCompile(`^[-.\w]+(?:/[-.\w]+)*$`)
 	reReserved = regexp.MustCompile(`(?i)^/?(?:aws|ssm)`)
+	reSelector = regexp.MustCompile(`^[-.\w]+$`)
+	reVersion  = regexp.MustCompile(`^[0-9]+$`)
 )
 
 func isName(name string) bool {
@@ -51,6 +53,40 @@ func abs(name string) string {
 	return "/" + name
 }
 
+// splitSelector splits "name:version" or "name:label" into the name and the selector.
+func splitSelector(name string) (string, string) {
+	i := strings.LastIndex(name, ":")
+	if i < 0 {
+		return name, ""
+	}
+	return name[:i], name[i+1:]
+}
+
+// validateNameWithSelector is validateName which allows a version or label selector at the end of the name.
+func validateNameWithSelector(name string) error {
+	base, selector := splitSelector(name)
+	if base != name && !reSelector.MatchString(selector) {
+		return ErrInvalidName{Name: name}
+	}
+	return validateName(base)
+}
+
+// joinWithSelector is join which allows a version or label selector at the end of the name.
+func joinWithSelector(path string, name string) (string, error) {
+	base, selector := splitSelector(name)
+	if base == name {
+		return join(path, name)
+	}
+	if !reSelector.MatchString(selector) {
+		return "", ErrInvalidName{Name: name}
+	}
+	joined, err := join(path, base)
+	if err != nil {
+		return "", err
+	}
+	return joined + ":" + selector, nil
+}
+
 func join(path string, name string) (string, error) {
 	if path != "" {
 		if err := validatePath(path); err != nil {
@@ -0,0 +1,645 @@
+package lib
+
+import (
+	"fmt"
+	"io"
+	gopath "path"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ssm"
+	"github.com/m4i/ssmenv/semaphore"
+)
+
+// Actions of a change.
+const (
+	actionUnchanged = "UNCHANGED"
+	actionPut       = "PUT"
+	actionDelete    = "DELETE"
+)
+
+// UpdateOptions are options for set and replace.
+type UpdateOptions struct {
+	// DryRun prints the plan without writing anything.
+	DryRun bool
+
+	// Confirm is called with the names to be deleted, and aborts the update unless it returns true.
+	Confirm func(deleteNames []string) (bool, error)
+
+	// MaxDeletes is the max number of parameters to be deleted. 0 means no limit.
+	MaxDeletes int
+
+	// MaxDeleteFraction is the max fraction of existing parameters to be deleted unless Force. 0 means no limit.
+	MaxDeleteFraction float64
+
+	// Protect is glob patterns of names which must not be deleted.
+	Protect []string
+
+	// Force ignores MaxDeleteFraction.
+	Force bool
+
+	// DeleteGracePeriod is the time to wait between putting and deleting parameters.
+	DeleteGracePeriod time.Duration
+
+	// KeyID is the KMS key ID of SecureString parameters which do not have their own.
+	KeyID string
+
+	// PathKeyIDs are the KMS key IDs by path used if KeyID is empty. The longest matching path is used.
+	PathKeyIDs map[string]string
+
+	// ExpiresIn gives an Expiration policy to the parameters put, which then become Advanced.
+	ExpiresIn time.Duration
+
+	// NotifyBefore gives an ExpirationNotification policy. It requires ExpiresIn.
+	NotifyBefore time.Duration
+
+	// NotifyNoChange gives a NoChangeNotification policy.
+	NotifyNoChange time.Duration
+
+	// Tier is the tier of parameters without their own, or TierAuto.
+	Tier string
+
+	// Schema is checked before writing anything if not nil.
+	Schema *Schema
+}
+
+// tier returns the tier of a parameter without its own, which is decided by its size for TierAuto.
+func (opts UpdateOptions) tier(param *fullParameter) string {
+	if opts.Tier != TierAuto {
+		return opts.Tier
+	}
+	if len(param.Value) > maxStandardValueSize {
+		return ssm.ParameterTierIntelligentTiering
+	}
+	return ""
+}
+
+// setDefaults sets the KMS key ID and the tier of params without their own.
+func (opts UpdateOptions) setDefaults(params []*fullParameter) error {
+	switch opts.Tier {
+	case "", TierAuto, ssm.ParameterTierStandard, ssm.ParameterTierAdvanced, ssm.ParameterTierIntelligentTiering:
+	default:
+		return ErrUnknownTier{Tier: opts.Tier}
+	}
+
+	for _, param := range params {
+		if param.Type == ssm.ParameterTypeSecureString && param.KeyID == "" {
+			param.KeyID = opts.keyID(param.Name)
+		}
+		if param.Tier == "" {
+			param.Tier = opts.tier(param)
+		}
+	}
+	return nil
+}
+
+// setPolicies gives the policies to params, which become Advanced unless they have a tier.
+// They are put only if the current parameters lack any of the types, not to extend their expiration.
+func setPolicies(params []*fullParameter, policies []string) {
+	if len(policies) == 0 {
+		return
+	}
+	for _, param := range params {
+		param.Policies = policies
+		if param.Tier == "" {
+			param.Tier = ssm.ParameterTierAdvanced
+		}
+	}
+}
+
+// checkPatterns fails before writing anything if a value does not match its allowed pattern.
+// The patterns are regular expressions of Go, which are almost compatible with the ones of SSM.
+func checkPatterns(changes []*change) error {
+	for _, ch := range changes {
+		if ch.action != actionPut || ch.param.AllowedPattern == "" {
+			continue
+		}
+		re, err := regexp.Compile(ch.param.AllowedPattern)
+		if err != nil {
+			return err
+		}
+		if !re.MatchString(ch.param.Value) {
+			return ErrPatternMismatch{Name: abs(ch.param.Name), Pattern: ch.param.AllowedPattern}
+		}
+	}
+	return nil
+}
+
+// checkSizes fails before writing anything if a value is too large for the tier it is put in,
+// or the tier can not be put.
+func checkSizes(changes []*change) error {
+	for _, ch := range changes {
+		if ch.action != actionPut {
+			continue
+		}
+		if err := checkTier(ch); err != nil {
+			return err
+		}
+		tier := ch.param.Tier
+		if tier == "" && ch.old != nil {
+			tier = ch.old.Tier
+		}
+		if tier == "" {
+			tier = ssm.ParameterTierStandard
+		}
+		if size := len(ch.param.Value); size > maxValueSize(tier) {
+			return ErrValueTooLarge{Name: abs(ch.param.Name), Size: size, Tier: tier, Max: maxValueSize(tier)}
+		}
+	}
+	return nil
+}
+
+// checkTier fails if a parameter with policies is put in Standard, or an Advanced parameter is downgraded to it.
+func checkTier(ch *change) error {
+	if ch.param.Tier != ssm.ParameterTierStandard {
+		return nil
+	}
+	if len(ch.param.Policies) > 0 {
+		return ErrPoliciesInStandard{Name: abs(ch.param.Name)}
+	}
+	if ch.old != nil && ch.old.Tier == ssm.ParameterTierAdvanced {
+		return ErrTierDowngrade{Name: abs(ch.param.Name)}
+	}
+	return nil
+}
+
+// keyID returns the KMS key ID for a SecureString parameter without its own, or "" for the default key.
+func (opts UpdateOptions) keyID(name string) string {
+	if opts.KeyID != "" {
+		return opts.KeyID
+	}
+
+	name = abs(name)
+	keyID, longest := "", -1
+	for path, id := range opts.PathKeyIDs {
+		path = strings.TrimSuffix(path, "/")
+		if (name == path || strings.HasPrefix(name, path+"/")) && len(path) > longest {
+			keyID, longest = id, len(path)
+		}
+	}
+	return keyID
+}
+
+// checkDeletes validates the planned deletions against the options, and asks for confirmation.
+// nolint: gocyclo
+func (opts UpdateOptions) checkDeletes(changes []*change, existing int) error {
+	var deleteNames []string
+	for _, ch := range changes {
+		if ch.action == actionDelete {
+			deleteNames = append(deleteNames, abs(ch.param.Name))
+		}
+	}
+	if len(deleteNames) == 0 {
+		return nil
+	}
+
+	for _, name := range deleteNames {
+		for _, pattern := range opts.Protect {
+			matched, err := gopath.Match(pattern, name)
+			if err != nil {
+				return err
+			}
+			if matched {
+				return ErrProtected{Name: name, Pattern: pattern}
+			}
+		}
+	}
+
+	if opts.MaxDeletes > 0 && len(deleteNames) > opts.MaxDeletes {
+		return ErrTooManyDeletes{Count: len(deleteNames), Max: opts.MaxDeletes}
+	}
+
+	if !opts.Force && opts.MaxDeleteFraction > 0 &&
+		float64(len(deleteNames)) > float64(existing)*opts.MaxDeleteFraction {
+		return ErrDeleteFraction{Count: len(deleteNames), Existing: existing, Max: opts.MaxDeleteFraction}
+	}
+
+	if opts.DryRun || opts.Confirm == nil {
+		return nil
+	}
+	ok, err := opts.Confirm(deleteNames)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrAborted
+	}
+	return nil
+}
+
+// A change is a planned operation for a parameter.
+type change struct {
+	action string
+	param  *fullParameter // only the name for DELETE
+	old    *fullParameter // nil if the parameter does not exist
+}
+
+func (ch *change) log() (string, error) {
+	if ch.action == actionDelete {
+		return ch.action + " " + abs(ch.param.Name), nil
+	}
+
+	expr, err := ch.param.expression().log()
+	if err != nil {
+		return "", err
+	}
+	line := ch.action + " " + expr
+	if ch.old == nil {
+		return line, nil
+	}
+	if ch.old.Type != ch.param.Type {
+		line += fmt.Sprintf(" (%s -> %s)", ch.old.Type, ch.param.Type)
+	}
+	if ch.old.KeyID != "" && ch.param.KeyID != "" && ch.old.KeyID != ch.param.KeyID {
+		line += fmt.Sprintf(" (KMS key %s -> %s)", ch.old.KeyID, ch.param.KeyID)
+	}
+	if ch.action == actionPut && ch.param.metadataDiffers(ch.old) {
+		line += " (metadata)"
+	}
+	return line, nil
+}
+
+// removeObsoleteTags removes the tags of the old parameter which are not given to the new one.
+func (ch *change) removeObsoleteTags(svc *ssm.SSM) error {
+	if ch.old == nil || ch.param.Tags == nil {
+		return nil
+	}
+	return removeTags(svc, ch.param.Name, ch.old.Tags, ch.param.Tags)
+}
+
+func (ch *change) rollbackLog() string {
+	name := abs(ch.param.Name)
+	if ch.old == nil {
+		return name + " (deleted)"
+	}
+	return fmt.Sprintf("%s (version %d)", name, ch.old.Version)
+}
+
+// planChanges compares params with the current parameters of names, and plans changes.
+// The current parameters are kept in changes to roll them back.
+func planChanges(svc *ssm.SSM, params []*fullParameter, names []*string, deleteNames []*string) ([]*change, error) {
+	allNames := append(append([]*string{}, names...), deleteNames...)
+	oldParamsByName, err := getOldParameters(svc, allNames, params)
+	if err != nil {
+		return nil, err
+	}
+
+	var changes []*change
+	for _, param := range params {
+		if err := validateName(param.Name); err != nil {
+			return nil, err
+		}
+
+		old := oldParamsByName[abs(param.Name)]
+		action := actionPut
+		if old != nil && !param.differs(old) && !param.metadataDiffers(old) {
+			action = actionUnchanged
+		}
+		changes = append(changes, &change{action: action, param: param, old: old})
+	}
+
+	for _, name := range deleteNames {
+		if err := validateName(*name); err != nil {
+			return nil, err
+		}
+
+		// Nothing to delete.
+		old := oldParamsByName[abs(*name)]
+		if old == nil {
+			continue
+		}
+		changes = append(changes, &change{action: actionDelete, param: &fullParameter{Name: *name}, old: old})
+	}
+
+	return changes, nil
+}
+
+// getOldParameters returns the current parameters of names by their absolute names.
+// Their metadata and tags are also returned only if params have any to compare with.
+func getOldParameters(svc *ssm.SSM, names []*string, params []*fullParameter) (map[string]*fullParameter, error) {
+	oldParams, err := GetParametersByNames(svc, names)
+	if err != nil {
+		return nil, err
+	}
+	oldParamsByName := make(map[string]*fullParameter)
+	for _, param := range oldParams {
+		oldParamsByName[abs(*param.Name)] = newFullParameter(param)
+	}
+
+	if !hasMetadata(params) {
+		return oldParamsByName, nil
+	}
+	if err = setOldMetadata(svc, names, oldParamsByName); err != nil {
+		return nil, err
+	}
+	if err = setOldTags(svc, params, oldParamsByName); err != nil {
+		return nil, err
+	}
+	return oldParamsByName, nil
+}
+
+// setOldMetadata sets the metadata of names to the current parameters in oldParamsByName.
+func setOldMetadata(svc *ssm.SSM, names []*string, oldParamsByName map[string]*fullParameter) error {
+	metas, err := describeParametersByNames(svc, names)
+	if err != nil {
+		return err
+	}
+	for _, meta := range metas {
+		old, ok := oldParamsByName[abs(*meta.Name)]
+		if !ok {
+			continue
+		}
+		if old.Type == ssm.ParameterTypeSecureString {
+			old.KeyID = aws.StringValue(meta.KeyId)
+		}
+		old.Description = aws.StringValue(meta.Description)
+		old.Tier = aws.StringValue(meta.Tier)
+		old.AllowedPattern = aws.StringValue(meta.AllowedPattern)
+		for _, policy := range meta.Policies {
+			old.Policies = append(old.Policies, aws.StringValue(policy.PolicyText))
+		}
+	}
+	return nil
+}
+
+// setOldTags sets the current tags to the parameters in oldParamsByName that params have tags to compare with.
+func setOldTags(svc *ssm.SSM, params []*fullParameter, oldParamsByName map[string]*fullParameter) error {
+	sem := semaphore.New(MaxConnection)
+	for _, param := range params {
+		old, ok := oldParamsByName[abs(param.Name)]
+		if !ok || param.Tags == nil {
+			continue
+		}
+		sem.Go(func() error {
+			tags, err := listTags(svc, old.Name)
+			if err != nil {
+				return err
+			}
+			old.Tags = tags
+			return nil
+		})
+	}
+	return sem.Wait()
+}
+
+// hasMetadata reports whether any of params has a KMS key ID, description, tier, tags, policies or allowed pattern,
+// or a value whose size depends on the current tier.
+func hasMetadata(params []*fullParameter) bool {
+	for _, param := range params {
+		if param.KeyID != "" || param.Description != "" || param.Tier != "" || param.Tags != nil {
+			return true
+		}
+		if param.AllowedPattern != "" || len(param.Policies) > 0 {
+			return true
+		}
+		if len(param.Value) > maxStandardValueSize {
+			return true
+		}
+	}
+	return false
+}
+
+func hasPendingChanges(changes []*change) bool {
+	for _, ch := range changes {
+		if ch.action != actionUnchanged {
+			return true
+		}
+	}
+	return false
+}
+
+// printPlan prints changes without applying them, and returns ErrChangesPending if any.
+func printPlan(changes []*change, log io.Writer) error {
+	println := newPrintln(log)
+	for _, ch := range changes {
+		line, err := ch.log()
+		if err != nil {
+			return err
+		}
+		println(line)
+	}
+
+	if hasPendingChanges(changes) {
+		return ErrChangesPending
+	}
+	return nil
+}
+
+// applyChanges puts parameters, verifies them, and then deletes obsolete parameters after the grace period,
+// so that readers never miss a key. The applied changes are rolled back if any change fails.
+func applyChanges(svc *ssm.SSM, changes []*change, log io.Writer, opts UpdateOptions) error {
+	println := newPrintln(log)
+
+	puts, deletes, err := splitChanges(changes, println)
+	if err != nil {
+		return err
+	}
+
+	applied, err := putConcurrently(svc, puts, println)
+	if err == nil {
+		err = verifyPuts(svc, puts)
+	}
+	if err == nil && len(deletes) > 0 {
+		if opts.DeleteGracePeriod > 0 {
+			println(fmt.Sprintf("WAIT %v", opts.DeleteGracePeriod))
+			time.Sleep(opts.DeleteGracePeriod)
+		}
+		var deleted []*change
+		deleted, err = deleteConcurrently(svc, deletes, println)
+		applied = append(applied, deleted...)
+	}
+
+	if err != nil {
+		return rollbackChanges(svc, applied, err, println)
+	}
+	return nil
+}
+
+// splitChanges returns the changes to put and to delete, printing the unchanged ones.
+func splitChanges(changes []*change, println func(string)) (puts, deletes []*change, err error) {
+	for _, ch := range changes {
+		switch ch.action {
+		case actionUnchanged:
+			var line string
+			if line, err = ch.log(); err != nil {
+				return nil, nil, err
+			}
+			println(line)
+		case actionPut:
+			puts = append(puts, ch)
+		case actionDelete:
+			deletes = append(deletes, ch)
+		}
+	}
+	return puts, deletes, nil
+}
+
+// putConcurrently puts parameters, and returns the changes applied successfully.
+func putConcurrently(svc *ssm.SSM, puts []*change, println func(string)) ([]*change, error) {
+	sem := semaphore.New(MaxConnection)
+
+	var mu sync.Mutex
+	var applied []*change
+
+	lines := make([]string, len(puts))
+	for i, ch := range puts {
+		line, err := ch.log()
+		if err != nil {
+			return nil, err
+		}
+		lines[i] = line
+	}
+
+	for i, ch := range puts {
+		ch, line := ch, lines[i]
+		sem.Go(func() error {
+			if err := putFullParameter(svc, ch.param); err != nil {
+				return err
+			}
+			mu.Lock()
+			applied = append(applied, ch)
+			mu.Unlock()
+			if err := ch.removeObsoleteTags(svc); err != nil {
+				return err
+			}
+			println(line)
+			return nil
+		})
+	}
+
+	err := sem.Wait()
+	return applied, err
+}
+
+// deleteConcurrently deletes parameters in batches, and returns the changes applied successfully.
+// The parameters which DeleteParameters returns as invalid are not applied, and fail the deletion.
+func deleteConcurrently(svc *ssm.SSM, deletes []*change, println func(string)) ([]*change, error) {
+	sem := semaphore.New(MaxConnection)
+
+	var mu sync.Mutex
+	var applied []*change
+	var invalid []string
+
+	for i := 0; i < len(deletes); i += maxNames {
+		batch := deletes[i:]
+		if len(batch) > maxNames {
+			batch = batch[:maxNames]
+		}
+		sem.Go(func() error {
+			names := make([]*string, len(batch))
+			changesByName := make(map[string]*change)
+			for j, ch := range batch {
+				names[j] = aws.String(ch.param.Name)
+				changesByName[ch.param.Name] = ch
+			}
+
+			output, err := svc.DeleteParameters(&ssm.DeleteParametersInput{Names: names})
+			if err != nil {
+				return err
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			for _, name := range output.DeletedParameters {
+				if ch, ok := changesByName[*name]; ok {
+					applied = append(applied, ch)
+					println(actionDelete + " " + abs(*name))
+				}
+			}
+			for _, name := range output.InvalidParameters {
+				invalid = append(invalid, abs(*name))
+			}
+			return nil
+		})
+	}
+
+	if err := sem.Wait(); err != nil {
+		return applied, err
+	}
+	if len(invalid) > 0 {
+		sort.Strings(invalid)
+		return applied, ErrNotDeleted{Names: invalid}
+	}
+	return applied, nil
+}
+
+// verifyPuts checks that the put parameters can be read with the new values.
+func verifyPuts(svc *ssm.SSM, puts []*change) error {
+	if len(puts) == 0 {
+		return nil
+	}
+
+	names := make([]*string, len(puts))
+	for i, ch := range puts {
+		names[i] = aws.String(ch.param.Name)
+	}
+	params, err := GetParametersByNames(svc, names)
+	if err != nil {
+		return err
+	}
+	paramsByName := make(map[string]*ssm.Parameter)
+	for _, param := range params {
+		paramsByName[abs(*param.Name)] = param
+	}
+
+	for _, ch := range puts {
+		name := abs(ch.param.Name)
+		param, ok := paramsByName[name]
+		if !ok || *param.Type != ch.param.Type || *param.Value != ch.param.Value {
+			return ErrVerify{Name: name}
+		}
+	}
+	return nil
+}
+
+// rollbackChanges reverts all the applied changes as far as possible, and reports the result as ErrPartialUpdate.
+func rollbackChanges(svc *ssm.SSM, applied []*change, cause error, println func(string)) error {
+	// Every revert is tried even if some fail, so the semaphore must not be canceled.
+	sem := semaphore.New(MaxConnection)
+
+	var mu sync.Mutex
+	result := ErrPartialUpdate{Cause: cause}
+
+	for _, ch := range applied {
+		ch := ch
+		sem.Go(func() error {
+			name := abs(ch.param.Name)
+			err := revertChange(svc, ch)
+
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				result.NotRolledBack = append(result.NotRolledBack, name)
+				println(fmt.Sprintf("ROLLBACK FAILED %s: %v", name, err))
+			} else {
+				result.RolledBack = append(result.RolledBack, name)
+				println("ROLLBACK " + ch.rollbackLog())
+			}
+			return nil
+		})
+	}
+	_ = sem.Wait()
+
+	sort.Strings(result.RolledBack)
+	sort.Strings(result.NotRolledBack)
+	return result
+}
+
+// revertChange restores the parameter of an applied change to its previous state.
+func revertChange(svc *ssm.SSM, ch *change) error {
+	if ch.old == nil {
+		_, err := svc.DeleteParameter(&ssm.DeleteParameterInput{Name: aws.String(ch.param.Name)})
+		return err
+	}
+	if err := putFullParameter(svc, ch.old); err != nil {
+		return err
+	}
+	if ch.param.Tags == nil {
+		return nil
+	}
+	return removeTags(svc, ch.old.Name, ch.param.Tags, ch.old.Tags)
+}
@@ -0,0 +1,214 @@
+package lib
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ssm"
+)
+
+// Types of parameter policies.
+const (
+	policyExpiration             = "Expiration"
+	policyExpirationNotification = "ExpirationNotification"
+	policyNoChangeNotification   = "NoChangeNotification"
+)
+
+// policyTimeLayout is the layout of the timestamp of an Expiration policy.
+const policyTimeLayout = "2006-01-02T15:04:05.000Z"
+
+// ParseDuration parses a duration like time.ParseDuration, which may also be given in days, e.g. 90d.
+func ParseDuration(value string) (time.Duration, error) {
+	if strings.HasSuffix(value, "d") {
+		days, err := strconv.Atoi(strings.TrimSuffix(value, "d"))
+		if err != nil || days < 0 {
+			return 0, ErrInvalidDuration{Value: value}
+		}
+		return time.Duration(days) * 24 * time.Hour, nil
+	}
+
+	d, err := time.ParseDuration(value)
+	if err != nil || d < 0 {
+		return 0, ErrInvalidDuration{Value: value}
+	}
+	return d, nil
+}
+
+// A policy is the text of a parameter policy.
+type policy struct {
+	Type       string
+	Version    string
+	Attributes map[string]string
+}
+
+func (p policy) text() (string, error) {
+	data, err := json.Marshal(p)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+// relativePolicy returns a notification policy whose attribute is in days if possible, otherwise in hours.
+func relativePolicy(_type string, key string, d time.Duration) (policy, error) {
+	if d <= 0 || d%time.Hour != 0 {
+		return policy{}, ErrInvalidDuration{Value: d.String()}
+	}
+	value, unit := int64(d/time.Hour), "Hours"
+	if d%(24*time.Hour) == 0 {
+		value, unit = int64(d/(24*time.Hour)), "Days"
+	}
+	return policy{
+		Type:       _type,
+		Version:    "1.0",
+		Attributes: map[string]string{key: strconv.FormatInt(value, 10), "Unit": unit},
+	}, nil
+}
+
+// policies returns the texts of the policies given to the parameters put, relative to now.
+func (opts UpdateOptions) policies(now time.Time) ([]string, error) {
+	var policies []policy
+	if opts.ExpiresIn > 0 {
+		policies = append(policies, policy{
+			Type:       policyExpiration,
+			Version:    "1.0",
+			Attributes: map[string]string{"Timestamp": now.Add(opts.ExpiresIn).UTC().Format(policyTimeLayout)},
+		})
+	}
+	if opts.NotifyBefore > 0 {
+		if opts.ExpiresIn <= 0 {
+			return nil, ErrNotifyBeforeWithoutExpiration
+		}
+		p, err := relativePolicy(policyExpirationNotification, "Before", opts.NotifyBefore)
+		if err != nil {
+			return nil, err
+		}
+		policies = append(policies, p)
+	}
+	if opts.NotifyNoChange > 0 {
+		p, err := relativePolicy(policyNoChangeNotification, "After", opts.NotifyNoChange)
+		if err != nil {
+			return nil, err
+		}
+		policies = append(policies, p)
+	}
+
+	texts := make([]string, len(policies))
+	for i, p := range policies {
+		text, err := p.text()
+		if err != nil {
+			return nil, err
+		}
+		texts[i] = text
+	}
+	return texts, nil
+}
+
+// policyTypes returns the types of the policy texts. Texts which can not be parsed have no type.
+func policyTypes(texts []string) []string {
+	var types []string
+	for _, text := range texts {
+		var p policy
+		if err := json.Unmarshal([]byte(text), &p); err == nil && p.Type != "" {
+			types = append(types, p.Type)
+		}
+	}
+	return types
+}
+
+// hasPolicyTypes reports whether the policy texts have all the types.
+func hasPolicyTypes(texts []string, types []string) bool {
+	has := make(map[string]bool)
+	for _, _type := range policyTypes(texts) {
+		has[_type] = true
+	}
+	for _, _type := range types {
+		if !has[_type] {
+			return false
+		}
+	}
+	return true
+}
+
+// expiration returns the timestamp of the Expiration policy of the parameter, or false if it has none.
+func expiration(meta *ssm.ParameterMetadata) (time.Time, bool, error) {
+	for _, inline := range meta.Policies {
+		if aws.StringValue(inline.PolicyType) != policyExpiration {
+			continue
+		}
+		var p policy
+		if err := json.Unmarshal([]byte(aws.StringValue(inline.PolicyText)), &p); err != nil {
+			return time.Time{}, false, err
+		}
+		t, err := time.Parse(time.RFC3339, p.Attributes["Timestamp"])
+		if err != nil {
+			return time.Time{}, false, err
+		}
+		return t, true, nil
+	}
+	return time.Time{}, false, nil
+}
+
+// An expiringEntry is a parameter which expires soon.
+type expiringEntry struct {
+	Name    string
+	Type    string
+	Expires time.Time
+}
+
+// Expiring is the implementation of `ssmenv expiring`.
+// It prints the parameters whose Expiration policies expire within the duration from now.
+func Expiring(w io.Writer, svc *ssm.SSM, path string, recursive bool, within time.Duration, format string) error {
+	switch format {
+	case FormatText, FormatJSON:
+	default:
+		return ErrUnknownFormat{Format: format}
+	}
+
+	metas, err := describeParameters(svc, []string{path}, recursive)
+	if err != nil {
+		return err
+	}
+
+	deadline := time.Now().Add(within)
+	entries := []expiringEntry{}
+	for _, meta := range metas {
+		expires, ok, err := expiration(meta)
+		if err != nil {
+			return err
+		}
+		if ok && !expires.After(deadline) {
+			entries = append(entries, expiringEntry{
+				Name:    abs(*meta.Name),
+				Type:    aws.StringValue(meta.Type),
+				Expires: expires.UTC(),
+			})
+		}
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		if !entries[i].Expires.Equal(entries[j].Expires) {
+			return entries[i].Expires.Before(entries[j].Expires)
+		}
+		return entries[i].Name < entries[j].Name
+	})
+
+	if format == FormatJSON {
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(entries)
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "EXPIRES\tTYPE\tNAME")
+	for _, entry := range entries {
+		fmt.Fprintf(tw, "%s\t%s\t%s\n", entry.Expires.Format(time.RFC3339), entry.Type, entry.Name)
+	}
+	return tw.Flush()
+}
@@ -0,0 +1,179 @@
+package lib
+
+import (
+	"bufio"
+	"encoding/base64"
+	"encoding/hex"
+	"io"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"os/exec"
+	"os/signal"
+	"sort"
+	"strings"
+	"syscall"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ssm"
+	"github.com/m4i/ssmenv/semaphore"
+)
+
+// Encodings of SecureString values redacted in addition to the values themselves.
+const (
+	EncodingBase64 = "base64"
+	EncodingURL    = "url"
+	EncodingHex    = "hex"
+)
+
+// redactedMark replaces secrets in output.
+const redactedMark = "****"
+
+// minRedactedSize is the size of the shortest secret redacted. Shorter ones such as "1" or a line of "{"
+// would redact unrelated output, and so would their encodings.
+const minRedactedSize = 4
+
+// encoders are the encoders of secrets by encodings. Each returns all the forms of a secret.
+var encoders = map[string]func(string) []string{
+	EncodingBase64: func(s string) []string {
+		return []string{
+			base64.StdEncoding.EncodeToString([]byte(s)),
+			base64.RawStdEncoding.EncodeToString([]byte(s)),
+			base64.URLEncoding.EncodeToString([]byte(s)),
+			base64.RawURLEncoding.EncodeToString([]byte(s)),
+		}
+	},
+	EncodingURL: func(s string) []string {
+		return []string{url.QueryEscape(s), url.PathEscape(s)}
+	},
+	EncodingHex: func(s string) []string {
+		return []string{hex.EncodeToString([]byte(s)), strings.ToUpper(hex.EncodeToString([]byte(s)))}
+	},
+}
+
+// checkEncodings returns an error if any of encodings is unknown.
+func checkEncodings(encodings []string) error {
+	for _, encoding := range encodings {
+		if _, ok := encoders[encoding]; !ok {
+			return ErrUnknownEncoding{Encoding: encoding}
+		}
+	}
+	return nil
+}
+
+// newRedactor returns a replacer of the SecureString values of params and their encodings.
+// Each line of a multiline value is replaced as well, since output is redacted line by line.
+// Values and lines shorter than minRedactedSize are left as they are.
+func newRedactor(params []*ssm.Parameter, encodings []string) *strings.Replacer {
+	seen := make(map[string]bool)
+	var secrets []string
+	add := func(secret string) {
+		if len(secret) >= minRedactedSize && !seen[secret] {
+			seen[secret] = true
+			secrets = append(secrets, secret)
+		}
+	}
+
+	for _, param := range params {
+		if aws.StringValue(param.Type) != ssm.ParameterTypeSecureString {
+			continue
+		}
+		for _, secret := range secretForms(*param.Value, encodings) {
+			add(secret)
+		}
+	}
+
+	// Longer secrets take precedence over shorter ones at the same position.
+	sort.Slice(secrets, func(i, j int) bool { return len(secrets[i]) > len(secrets[j]) })
+	oldnew := make([]string, 0, len(secrets)*2)
+	for _, secret := range secrets {
+		oldnew = append(oldnew, secret, redactedMark)
+	}
+	return strings.NewReplacer(oldnew...)
+}
+
+// secretForms returns a value, its encodings and the lines of a multiline value,
+// or nothing if the value is shorter than minRedactedSize.
+func secretForms(value string, encodings []string) []string {
+	if len(value) < minRedactedSize {
+		return nil
+	}
+
+	forms := []string{value}
+	for _, encoding := range encodings {
+		forms = append(forms, encoders[encoding](value)...)
+	}
+	if strings.Contains(value, "\n") {
+		for _, line := range strings.Split(value, "\n") {
+			forms = append(forms, strings.TrimSuffix(line, "\r"))
+		}
+	}
+	return forms
+}
+
+// redactLines copies r to w line by line with secrets replaced.
+// The rest of r is discarded after w fails, so that the writer to r is not blocked.
+func redactLines(w io.Writer, r io.Reader, redactor *strings.Replacer) error {
+	reader := bufio.NewReader(r)
+	for {
+		line, err := reader.ReadString('\n')
+		if line != "" {
+			if _, werr := io.WriteString(w, redactor.Replace(line)); werr != nil {
+				io.Copy(ioutil.Discard, reader) // nolint: errcheck
+				return werr
+			}
+		}
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// execRedacted runs the command with its stdout and stderr redacted.
+// SIGTERM and SIGHUP to ssmenv are relayed to the command, and SIGINT and SIGQUIT are ignored
+// because the terminal sends them to the command in the same process group as well.
+// Either way ssmenv exits after the command and its last output.
+func execRedacted(argv0 string, argv []string, envs []string, redactor *strings.Replacer) error {
+	command := exec.Command(argv0, argv[1:]...)
+	command.Env = envs
+	command.Stdin = os.Stdin
+	stdout, err := command.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	stderr, err := command.StderrPipe()
+	if err != nil {
+		return err
+	}
+	if err = command.Start(); err != nil {
+		return err
+	}
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM, syscall.SIGHUP, syscall.SIGQUIT)
+	defer func() {
+		signal.Stop(signals)
+		close(signals)
+	}()
+	go func() {
+		for sig := range signals {
+			if sig == syscall.SIGINT || sig == syscall.SIGQUIT {
+				continue
+			}
+			command.Process.Signal(sig) // nolint: errcheck
+		}
+	}()
+
+	sem := semaphore.New(2)
+	sem.Go(func() error { return redactLines(os.Stdout, stdout, redactor) })
+	sem.Go(func() error { return redactLines(os.Stderr, stderr, redactor) })
+	copyErr := sem.Wait()
+
+	if err = command.Wait(); err != nil {
+		return err
+	}
+	return copyErr
+}
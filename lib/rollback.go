@@ -0,0 +1,162 @@
+package lib
+
+import (
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ssm"
+	"github.com/m4i/ssmenv/semaphore"
+)
+
+// timeLayouts are the layouts accepted by ParseTime.
+var timeLayouts = []string{
+	time.RFC3339,
+	"2006-01-02T15:04Z07:00",
+	"2006-01-02T15:04:05",
+	"2006-01-02T15:04",
+	"2006-01-02",
+}
+
+// ParseTime parses a point in time like 2006-01-02T15:04Z. A time without a zone is in UTC.
+func ParseTime(value string) (time.Time, error) {
+	for _, layout := range timeLayouts {
+		t, err := time.Parse(layout, strings.ToUpper(value))
+		if err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, ErrInvalidTime{Value: value}
+}
+
+// RollbackOptions are options for rollback.
+type RollbackOptions struct {
+	// Version is the version to roll back a parameter to.
+	Version int64
+
+	// At is the point in time to roll back to, used if Version is 0.
+	At time.Time
+
+	// Recursive rolls back all parameters within a hierarchy.
+	Recursive bool
+}
+
+// find returns the version of the history to roll back to, or nil if the parameter did not exist yet.
+// Only the latest versions are retained, so a parameter without version 1 existed before its history.
+func (rbOpts RollbackOptions) find(name string, history []*ssm.ParameterHistory) (*ssm.ParameterHistory, error) {
+	sort.Slice(history, func(i, j int) bool {
+		return aws.Int64Value(history[i].Version) < aws.Int64Value(history[j].Version)
+	})
+
+	if rbOpts.Version > 0 {
+		for _, h := range history {
+			if aws.Int64Value(h.Version) == rbOpts.Version {
+				return h, nil
+			}
+		}
+		return nil, ErrVersionNotFound{Name: abs(name), Version: rbOpts.Version}
+	}
+
+	var found *ssm.ParameterHistory
+	for _, h := range history {
+		if aws.TimeValue(h.LastModifiedDate).After(rbOpts.At) {
+			break
+		}
+		found = h
+	}
+	if found == nil && len(history) > 0 && aws.Int64Value(history[0].Version) != 1 {
+		return nil, ErrHistoryNotRetained{Name: abs(name), At: rbOpts.At}
+	}
+	return found, nil
+}
+
+// Rollback is the implementation of `ssmenv rollback`.
+// It puts the values of the given version or point in time again, and deletes the parameters
+// created after the point in time. Parameters deleted after that can not be restored because
+// their history is deleted with them.
+func Rollback(
+	w io.Writer,
+	svc *ssm.SSM,
+	path string,
+	name string,
+	rbOpts RollbackOptions,
+	opts UpdateOptions,
+) error {
+	names, err := rollbackNames(svc, path, name, rbOpts.Recursive)
+	if err != nil {
+		return err
+	}
+	founds, err := findHistories(svc, names, rbOpts)
+	if err != nil {
+		return err
+	}
+
+	var params []*fullParameter
+	var putNames, deleteNames []*string
+	for i, found := range founds {
+		if found == nil {
+			deleteNames = append(deleteNames, names[i])
+			continue
+		}
+		putNames = append(putNames, names[i])
+		param := &fullParameter{
+			Name:  *names[i],
+			Type:  *found.Type,
+			Value: *found.Value,
+		}
+		if param.Type == ssm.ParameterTypeSecureString {
+			param.KeyID = aws.StringValue(found.KeyId)
+		}
+		params = append(params, param)
+	}
+
+	return updateParameters(svc, params, putNames, deleteNames, w, opts)
+}
+
+// rollbackNames returns the sorted absolute names of the parameters to roll back.
+func rollbackNames(svc *ssm.SSM, path string, name string, recursive bool) ([]*string, error) {
+	if name != "" {
+		joined, err := join(path, name)
+		if err != nil {
+			return nil, err
+		}
+		return []*string{&joined}, nil
+	}
+	if path == "" {
+		return nil, ErrRequirePath
+	}
+
+	metas, err := describeParameters(svc, []string{path}, recursive)
+	if err != nil {
+		return nil, err
+	}
+	names := make([]*string, len(metas))
+	for i, meta := range metas {
+		names[i] = meta.Name
+	}
+	sort.Slice(names, func(i, j int) bool { return *names[i] < *names[j] })
+	return names, nil
+}
+
+// findHistories returns the history entries of names to roll back to, or nil for parameters created after that.
+func findHistories(svc *ssm.SSM, names []*string, rbOpts RollbackOptions) ([]*ssm.ParameterHistory, error) {
+	founds := make([]*ssm.ParameterHistory, len(names))
+	sem := semaphore.New(MaxConnection)
+	for i, name := range names {
+		i, name := i, name
+		sem.Go(func() error {
+			history, err := getParameterHistory(svc, *name)
+			if err != nil {
+				return err
+			}
+			founds[i], err = rbOpts.find(*name, history)
+			return err
+		})
+	}
+	if err := sem.Wait(); err != nil {
+		return nil, err
+	}
+	return founds, nil
+}
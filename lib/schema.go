@@ -0,0 +1,170 @@
+package lib
+
+import (
+	"fmt"
+	"io/ioutil"
+	gopath "path"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/service/ssm"
+	yaml "gopkg.in/yaml.v2"
+)
+
+// A Schema is the rules of parameters checked before writing them.
+type Schema struct {
+	// Parameters are the rules by glob patterns of names relative to a path.
+	Parameters map[string]*Rule `yaml:"parameters"`
+}
+
+// A Rule is the rule of the parameters matching a pattern.
+type Rule struct {
+	// Required requires at least one parameter to match the pattern.
+	Required bool `yaml:"required"`
+
+	// Pattern is a regular expression which values must match.
+	Pattern string `yaml:"pattern"`
+
+	// Enum is the values allowed if not empty.
+	Enum []string `yaml:"enum"`
+
+	// MinLength and MaxLength are the lengths of values allowed. MaxLength is unlimited if 0.
+	MinLength int `yaml:"min_length"`
+	MaxLength int `yaml:"max_length"`
+
+	// Secure requires parameters to be SecureString.
+	Secure bool `yaml:"secure"`
+
+	re *regexp.Regexp
+}
+
+// LoadSchema reads a YAML schema file.
+func LoadSchema(filename string) (*Schema, error) {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	var schema Schema
+	if err := yaml.Unmarshal(data, &schema); err != nil {
+		return nil, err
+	}
+
+	for pattern, rule := range schema.Parameters {
+		if _, err := gopath.Match(pattern, ""); err != nil {
+			return nil, ErrInvalidSchema{Pattern: pattern, Reason: err.Error()}
+		}
+		if rule == nil {
+			schema.Parameters[pattern] = &Rule{}
+			continue
+		}
+		if rule.Pattern != "" {
+			if rule.re, err = regexp.Compile(rule.Pattern); err != nil {
+				return nil, ErrInvalidSchema{Pattern: pattern, Reason: err.Error()}
+			}
+		}
+	}
+	return &schema, nil
+}
+
+// violation returns why the parameter violates the rule, or "" if it does not.
+func (r *Rule) violation(param *fullParameter) string {
+	value := param.Value
+	switch {
+	case r.Secure && param.Type != ssm.ParameterTypeSecureString:
+		return "must be SecureString"
+	case r.re != nil && !r.re.MatchString(value):
+		return fmt.Sprintf("does not match %s", r.Pattern)
+	case len(r.Enum) > 0 && !contains(r.Enum, value):
+		return fmt.Sprintf("is not one of %s", strings.Join(r.Enum, ", "))
+	case len(value) < r.MinLength:
+		return fmt.Sprintf("is shorter than %d", r.MinLength)
+	case r.MaxLength > 0 && len(value) > r.MaxLength:
+		return fmt.Sprintf("is longer than %d", r.MaxLength)
+	default:
+		return ""
+	}
+}
+
+// check checks the parameters keyed by relative names, and checks required ones only if complete.
+// Values are never included in the violations.
+func (s *Schema) check(snapshot Snapshot, complete bool) error {
+	if s == nil {
+		return nil
+	}
+
+	var violations []string
+	for pattern, rule := range s.Parameters {
+		matched := false
+		for name, param := range snapshot {
+			if !matchName(pattern, name) {
+				continue
+			}
+			matched = true
+			if v := rule.violation(param); v != "" {
+				violations = append(violations, name+" "+v)
+			}
+		}
+		if complete && rule.Required && !matched {
+			violations = append(violations, pattern+" is required")
+		}
+	}
+
+	if len(violations) == 0 {
+		return nil
+	}
+	sort.Strings(violations)
+	return ErrSchemaViolations{Violations: violations}
+}
+
+// checkParameters checks params relative to a path, or by absolute names without a path.
+func (s *Schema) checkParameters(path string, params []*fullParameter, complete bool) error {
+	if s == nil {
+		return nil
+	}
+	snapshot := make(Snapshot)
+	for _, param := range params {
+		name, err := rel(param.Name, path)
+		if err != nil {
+			return err
+		}
+		snapshot[name] = param
+	}
+	return s.check(snapshot, complete)
+}
+
+// matchName reports whether a name relative to a path matches a pattern.
+// An absolute name matches if its name relative to any ancestor does,
+// e.g. DATABASE_URL matches /Prod/DATABASE_URL as it does DATABASE_URL with --path /Prod.
+func matchName(pattern string, name string) bool {
+	if !strings.HasPrefix(name, "/") {
+		ok, _ := gopath.Match(pattern, name)
+		return ok
+	}
+
+	parts := strings.Split(name[1:], "/")
+	for i := range parts {
+		if ok, _ := gopath.Match(pattern, strings.Join(parts[i:], "/")); ok {
+			return true
+		}
+	}
+	return false
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// Validate is the implementation of `ssmenv validate`.
+func Validate(svc *ssm.SSM, path string, recursive bool, schema *Schema) error {
+	snapshot, err := SnapshotPath(svc, path, recursive)
+	if err != nil {
+		return err
+	}
+	return schema.check(snapshot, true)
+}
@@ -0,0 +1,126 @@
+package lib
+
+import (
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ssm"
+)
+
+// searchContext is the number of bytes printed around a match in a value.
+const searchContext = 8
+
+// SearchOptions are options for search.
+type SearchOptions struct {
+	// Values searches decrypted values as well as names.
+	Values bool
+
+	// Regex treats the pattern as a regular expression instead of a plain string.
+	Regex bool
+}
+
+// maskedContext returns the value around the match whose location is loc, with the match masked.
+// The context of a SecureString value is masked as well.
+func maskedContext(value string, loc []int, secure bool) string {
+	start := loc[0] - searchContext
+	if start < 0 {
+		start = 0
+	}
+	for start > 0 && !utf8.RuneStart(value[start]) {
+		start--
+	}
+	end := loc[1] + searchContext
+	if end > len(value) {
+		end = len(value)
+	}
+	for end < len(value) && !utf8.RuneStart(value[end]) {
+		end++
+	}
+
+	before, after := value[start:loc[0]], value[loc[1]:end]
+	if secure {
+		before = strings.Repeat("*", utf8.RuneCountInString(before))
+		after = strings.Repeat("*", utf8.RuneCountInString(after))
+	}
+	if start > 0 {
+		before = "..." + before
+	}
+	if end < len(value) {
+		after += "..."
+	}
+	return before + strings.Repeat("*", utf8.RuneCountInString(value[loc[0]:loc[1]])) + after
+}
+
+// Search is the implementation of `ssmenv search`.
+// It prints the names of the parameters within the hierarchy of the path which match the pattern,
+// followed by the masked context of the match in the value if opts.Values.
+func Search(w io.Writer, svc *ssm.SSM, path string, pattern string, opts SearchOptions) error {
+	if !opts.Regex {
+		pattern = regexp.QuoteMeta(pattern)
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return err
+	}
+
+	metas, err := describeParameters(svc, []string{path}, true)
+	if err != nil {
+		return err
+	}
+
+	results := make(map[string]string)
+	var names []*string
+	for _, meta := range metas {
+		if re.MatchString(abs(*meta.Name)) {
+			results[abs(*meta.Name)] = ""
+		}
+		names = append(names, meta.Name)
+	}
+
+	if opts.Values {
+		if err = searchValues(svc, names, re, results); err != nil {
+			return err
+		}
+	}
+
+	printSearchResults(w, results)
+	return nil
+}
+
+// printSearchResults prints the names of results in order, followed by their contexts if any.
+func printSearchResults(w io.Writer, results map[string]string) {
+	matches := make([]string, 0, len(results))
+	for name := range results {
+		matches = append(matches, name)
+	}
+	sort.Strings(matches)
+	for _, name := range matches {
+		if context := results[name]; context != "" {
+			fmt.Fprintf(w, "%s: %s\n", name, context)
+		} else {
+			fmt.Fprintln(w, name)
+		}
+	}
+}
+
+// searchValues sets the masked contexts of the matches in the values of names to results.
+func searchValues(svc *ssm.SSM, names []*string, re *regexp.Regexp, results map[string]string) error {
+	params, err := GetParametersByNames(svc, names)
+	if err != nil {
+		return err
+	}
+	for _, param := range params {
+		loc := re.FindStringIndex(aws.StringValue(param.Value))
+		if loc == nil {
+			continue
+		}
+		secure := aws.StringValue(param.Type) == ssm.ParameterTypeSecureString
+		results[abs(*param.Name)] = maskedContext(*param.Value, loc, secure)
+	}
+	return nil
+}
@@ -1,6 +1,7 @@
 package lib
 
 import (
+	"bytes"
 	"fmt"
 	"io"
 	"os"
@@ -15,37 +16,50 @@ import (
 // UseCommandInsteadOfExec is a flag to use exec.Command instead of syscall.Exec for testing.
 var UseCommandInsteadOfExec = false
 
+// ExecOptions are options for exec.
+type ExecOptions struct {
+	// Recursive retrieves all parameters within a hierarchy.
+	Recursive bool
+
+	// Filter selects the parameters given to the command.
+	Filter Filter
+
+	// RedactOutput replaces SecureString values in the stdout and stderr of the command.
+	RedactOutput bool
+
+	// RedactEncodings are the encodings of SecureString values replaced as well, e.g. EncodingBase64.
+	RedactEncodings []string
+}
+
 // Exec is the implementation of `ssmenv exec`.
-func Exec(svc *ssm.SSM, paths []string, recursive bool, argv []string) error {
+func Exec(svc *ssm.SSM, paths []string, execOpts ExecOptions, argv []string) error {
 	if len(paths) == 0 {
 		paths = append(paths, "")
 	}
 	if len(argv) == 0 {
 		return ErrRequireCommand
 	}
+	if err := checkEncodings(execOpts.RedactEncodings); err != nil {
+		return err
+	}
 
 	argv0, err := exec.LookPath(argv[0])
 	if err != nil {
 		return err
 	}
 
-	params, err := getParametersByPaths(svc, paths, recursive)
+	params, err := getParametersByPaths(svc, paths, execOpts.Recursive, execOpts.Filter)
 	if err != nil {
 		return err
 	}
 
-	paramsByName := make(map[string]*ssm.Parameter)
-	for _, param := range params {
-		paramsByName[gopath.Base(*param.Name)] = param
+	envs, err := environ(params)
+	if err != nil {
+		return err
 	}
 
-	envs := os.Environ()
-	for _, param := range paramsByName {
-		env, err := newExpression(param).env() // nolint: vetshadow
-		if err != nil {
-			return err
-		}
-		envs = append(envs, env)
+	if execOpts.RedactOutput {
+		return execRedacted(argv0, argv, envs, newRedactor(params, execOpts.RedactEncodings))
 	}
 
 	if !UseCommandInsteadOfExec {
@@ -60,18 +74,129 @@ func Exec(svc *ssm.SSM, paths []string, recursive bool, argv []string) error {
 	return err
 }
 
+// environ returns the environment of ssmenv with params. Later params win for the same base name.
+func environ(params []*ssm.Parameter) ([]string, error) {
+	paramsByName := make(map[string]*ssm.Parameter)
+	for _, param := range params {
+		paramsByName[gopath.Base(*param.Name)] = param
+	}
+
+	envs := os.Environ()
+	for _, param := range paramsByName {
+		env, err := newExpression(param).env()
+		if err != nil {
+			return nil, err
+		}
+		envs = append(envs, env)
+	}
+	return envs, nil
+}
+
+// GetOptions are options for get.
+type GetOptions struct {
+	// Recursive retrieves all parameters within a hierarchy.
+	Recursive bool
+
+	// Export prints export statements for shells in FormatText.
+	Export bool
+
+	// Format is FormatText, FormatJSON or FormatYAML.
+	Format string
+
+	// Metadata prints the type, tier and size of each parameter with its value in FormatJSON or FormatYAML.
+	Metadata bool
+
+	// Filter selects the parameters printed.
+	Filter Filter
+
+	// Reveal prints SecureString values in plaintext instead of masking them.
+	Reveal bool
+
+	// NoDecrypt retrieves SecureString values without decryption, so they are always masked.
+	NoDecrypt bool
+
+	// Passphrase encrypts the output if not nil.
+	Passphrase []byte
+}
+
+// value returns the value of the parameter to print.
+func (getOpts GetOptions) value(param *ssm.Parameter) string {
+	if getOpts.Reveal && !getOpts.NoDecrypt {
+		return *param.Value
+	}
+	return newExpression(param).maskedValue()
+}
+
+// checkMasked fails if any of params is a SecureString whose value would be masked.
+// Masked values must not be exported or printed alone, where they would be taken for the real ones.
+func (getOpts GetOptions) checkMasked(params []*ssm.Parameter) error {
+	if getOpts.Reveal && !getOpts.NoDecrypt {
+		return nil
+	}
+	var names []string
+	for _, param := range params {
+		if aws.StringValue(param.Type) == ssm.ParameterTypeSecureString {
+			names = append(names, abs(*param.Name))
+		}
+	}
+	if len(names) > 0 {
+		return ErrMaskedValues{Names: names}
+	}
+	return nil
+}
+
 // GetByPath is the implementation of `ssmenv get`.
-func GetByPath(w io.Writer, svc *ssm.SSM, path string, recursive bool, exportFlag bool) error {
-	params, err := GetParametersByPath(svc, path, recursive)
+// Parameters are printed as expressions in FormatText, or as an object in FormatJSON or FormatYAML.
+// The output is encrypted if getOpts.Passphrase is given, so that import can decrypt it.
+func GetByPath(w io.Writer, svc *ssm.SSM, path string, getOpts GetOptions) error {
+	if getOpts.Passphrase != nil {
+		var buf bytes.Buffer
+		passphrase := getOpts.Passphrase
+		getOpts.Passphrase = nil
+		if err := GetByPath(&buf, svc, path, getOpts); err != nil {
+			return err
+		}
+		return encryptTo(w, buf.Bytes(), passphrase)
+	}
+
+	switch getOpts.Format {
+	case FormatText, FormatJSON, FormatYAML:
+	default:
+		return ErrUnknownFormat{Format: getOpts.Format}
+	}
+
+	params, err := getFilteredParameters(svc, path, getOpts.Recursive, getOpts.Filter, !getOpts.NoDecrypt)
 	if err != nil {
 		return err
 	}
+	path, _ = splitSelector(path)
 
+	if getOpts.Format != FormatText {
+		var tiers map[string]string
+		if getOpts.Metadata {
+			if tiers, err = getTiers(svc, path, getOpts.Recursive); err != nil {
+				return err
+			}
+		}
+		return writeParameters(w, params, path, tiers, getOpts)
+	}
+
+	return writeExpressions(w, params, path, getOpts)
+}
+
+// writeExpressions prints params as expressions relative to the path, or as export statements.
+func writeExpressions(w io.Writer, params []*ssm.Parameter, path string, getOpts GetOptions) error {
+	if getOpts.Export {
+		if err := getOpts.checkMasked(params); err != nil {
+			return err
+		}
+	}
 	for _, param := range params {
 		expr := newExpression(param)
+		expr.Value = getOpts.value(param)
 		var line string
 		var err error
-		if exportFlag {
+		if getOpts.Export {
 			line, err = expr.export()
 		} else {
 			line, err = expr.serialize(path)
@@ -81,56 +206,87 @@ func GetByPath(w io.Writer, svc *ssm.SSM, path string, recursive bool, exportFla
 		}
 		fmt.Fprintln(w, line)
 	}
-
 	return nil
 }
 
+// getTiers returns the tiers of the parameters of the path by their names.
+func getTiers(svc *ssm.SSM, path string, recursive bool) (map[string]string, error) {
+	metas, err := describeParameters(svc, []string{path}, recursive)
+	if err != nil {
+		return nil, err
+	}
+	tiers := make(map[string]string)
+	for _, meta := range metas {
+		tiers[*meta.Name] = aws.StringValue(meta.Tier)
+	}
+	return tiers, nil
+}
+
 // GetByName is the implementation of `ssmenv get NAME`.
-func GetByName(w io.Writer, svc *ssm.SSM, path string, name string) error {
-	name, err := join(path, name)
+// Only getOpts.Reveal and getOpts.NoDecrypt are used.
+func GetByName(w io.Writer, svc *ssm.SSM, path string, name string, getOpts GetOptions) error {
+	name, err := joinWithSelector(path, name)
 	if err != nil {
 		return err
 	}
 
 	output, err := svc.GetParameter(&ssm.GetParameterInput{
 		Name:           &name,
-		WithDecryption: aws.Bool(true),
+		WithDecryption: aws.Bool(!getOpts.NoDecrypt),
 	})
 	if err != nil {
 		return err
 	}
+	if err = getOpts.checkMasked([]*ssm.Parameter{output.Parameter}); err != nil {
+		return err
+	}
 
-	fmt.Fprintln(w, *output.Parameter.Value)
+	fmt.Fprintln(w, getOpts.value(output.Parameter))
 
 	return nil
 }
 
+// parseParameters parses annotated expressions relative to a path.
+func parseParameters(path string, exprs []string) ([]*fullParameter, error) {
+	exprObjs, err := parseExpressions(exprs)
+	if err != nil {
+		return nil, err
+	}
+
+	params := make([]*fullParameter, len(exprObjs))
+	for i, exprObj := range exprObjs {
+		params[i], err = exprObj.parameter(path)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return params, nil
+}
+
 // Set is the implementation of `ssmenv set`.
-func Set(w io.Writer, svc *ssm.SSM, path string, exprs []string) error {
+func Set(w io.Writer, svc *ssm.SSM, path string, exprs []string, opts UpdateOptions) error {
 	if len(exprs) < 1 {
 		return ErrRequireNameAndValue
 	}
 
-	var params []*ssm.Parameter
+	params, err := parseParameters(path, exprs)
+	if err != nil {
+		return err
+	}
+	if err := opts.Schema.checkParameters(path, params, false); err != nil {
+		return err
+	}
+
 	var names []*string
-	for _, expr := range exprs {
-		exprObj, err := parseExpression(expr)
-		if err != nil {
-			return err
-		}
-		param, err := exprObj.parameter(path)
-		if err != nil {
-			return err
-		}
-		params = append(params, param)
-		names = append(names, param.Name)
+	for _, param := range params {
+		names = append(names, aws.String(param.Name))
 	}
 
-	return updateParameters(svc, params, names, []*string{}, w)
+	return updateParameters(svc, params, names, []*string{}, w, opts)
 }
 
 // Replace is the implementation of `ssmenv replace`.
-func Replace(w io.Writer, svc *ssm.SSM, path string, recursive bool, exprs []string) error {
+func Replace(w io.Writer, svc *ssm.SSM, path string, recursive bool, exprs []string, opts UpdateOptions) error {
 	if path == "" {
 		return ErrRequirePath
 	}
@@ -138,8 +294,10 @@ func Replace(w io.Writer, svc *ssm.SSM, path string, recursive bool, exprs []str
 		return ErrRequireNameAndValue
 	}
 
-	var params []*ssm.Parameter
 	for _, expr := range exprs {
+		if IsAnnotation(expr) {
+			continue
+		}
 		exprObj, err := parseExpression(expr)
 		if err != nil {
 			return err
@@ -147,12 +305,74 @@ func Replace(w io.Writer, svc *ssm.SSM, path string, recursive bool, exprs []str
 		if !recursive && !isBase(exprObj.Name) {
 			return ErrSlashWithoutRecursive{Expr: expr}
 		}
-		param, err := exprObj.parameter(path)
+	}
+
+	params, err := parseParameters(path, exprs)
+	if err != nil {
+		return err
+	}
+	if err := opts.Schema.checkParameters(path, params, true); err != nil {
+		return err
+	}
+
+	return replaceParameters(svc, path, recursive, params, w, opts)
+}
+
+// Delete is the implementation of `ssmenv delete`.
+// The names are deleted if given, otherwise all the parameters of the path.
+// Only the names matching the glob pattern are deleted if it is given.
+func Delete(
+	w io.Writer,
+	svc *ssm.SSM,
+	path string,
+	recursive bool,
+	match string,
+	names []string,
+	opts UpdateOptions,
+) error {
+	var deleteNames []*string
+	if len(names) > 0 {
+		for _, name := range names {
+			fullName, err := join(path, name)
+			if err != nil {
+				return err
+			}
+			deleteNames = append(deleteNames, &fullName)
+		}
+	} else {
+		if path == "" {
+			return ErrRequirePath
+		}
+		metas, err := describeParameters(svc, []string{path}, recursive)
 		if err != nil {
 			return err
 		}
-		params = append(params, param)
+		for _, meta := range metas {
+			deleteNames = append(deleteNames, meta.Name)
+		}
 	}
 
-	return ReplaceParameters(svc, path, recursive, params, w)
+	if match != "" {
+		var err error
+		if deleteNames, err = matchNames(deleteNames, match); err != nil {
+			return err
+		}
+	}
+
+	return updateParameters(svc, nil, nil, deleteNames, w, opts)
+}
+
+// matchNames returns the names whose absolute names match the glob pattern.
+func matchNames(names []*string, match string) ([]*string, error) {
+	var matchedNames []*string
+	for _, name := range names {
+		matched, err := gopath.Match(match, abs(*name))
+		if err != nil {
+			return nil, err
+		}
+		if matched {
+			matchedNames = append(matchedNames, name)
+		}
+	}
+	return matchedNames, nil
 }
@@ -0,0 +1,118 @@
+package lib
+
+import (
+	"io"
+	gopath "path"
+	"sort"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ssm"
+)
+
+// SyncOptions are options for sync.
+type SyncOptions struct {
+	// Recursive syncs all parameters within the hierarchies.
+	Recursive bool
+
+	// Delete deletes the parameters of dst which do not exist in src.
+	Delete bool
+
+	// Exclude is glob patterns of names relative to src and dst which are left as they are.
+	Exclude []string
+}
+
+// excluded reports whether the relative name matches any of the exclude patterns.
+func (opts SyncOptions) excluded(name string) (bool, error) {
+	for _, pattern := range opts.Exclude {
+		matched, err := gopath.Match(pattern, name)
+		if err != nil {
+			return false, err
+		}
+		if matched {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// Sync is the implementation of `ssmenv sync`.
+// It updates the parameters of dst to be the same as src, and deletes the others only if syncOpts.Delete.
+func Sync(
+	w io.Writer,
+	srcSvc *ssm.SSM,
+	src string,
+	dstSvc *ssm.SSM,
+	dst string,
+	syncOpts SyncOptions,
+	opts UpdateOptions,
+) error {
+	if src == "" || dst == "" {
+		return ErrRequirePath
+	}
+
+	srcSnapshot, err := SnapshotPath(srcSvc, src, syncOpts.Recursive)
+	if err != nil {
+		return err
+	}
+	dstSnapshot, err := SnapshotPath(dstSvc, dst, syncOpts.Recursive)
+	if err != nil {
+		return err
+	}
+
+	params, err := syncOpts.parameters(srcSnapshot, dst)
+	if err != nil {
+		return err
+	}
+	names, deleteNames, err := syncOpts.names(srcSnapshot, dstSnapshot)
+	if err != nil {
+		return err
+	}
+
+	return updateParameters(dstSvc, params, names, deleteNames, w, opts)
+}
+
+// parameters returns the parameters of srcSnapshot to put under dst, except the excluded ones.
+func (syncOpts SyncOptions) parameters(srcSnapshot Snapshot, dst string) ([]*fullParameter, error) {
+	srcNames := make([]string, 0, len(srcSnapshot))
+	for name := range srcSnapshot {
+		srcNames = append(srcNames, name)
+	}
+	sort.Strings(srcNames)
+
+	var params []*fullParameter
+	for _, name := range srcNames {
+		excluded, err := syncOpts.excluded(name)
+		if err != nil {
+			return nil, err
+		}
+		if excluded {
+			continue
+		}
+		param := srcSnapshot[name]
+		params = append(params, &fullParameter{
+			Name:  gopath.Join(dst, name),
+			Type:  param.Type,
+			Value: param.Value,
+		})
+	}
+	return params, nil
+}
+
+// names returns the names of dstSnapshot to compare with srcSnapshot, and those to delete only if syncOpts.Delete.
+func (syncOpts SyncOptions) names(srcSnapshot, dstSnapshot Snapshot) (names, deleteNames []*string, err error) {
+	for name, param := range dstSnapshot {
+		var excluded bool
+		if excluded, err = syncOpts.excluded(name); err != nil {
+			return nil, nil, err
+		}
+		switch {
+		case excluded:
+		case srcSnapshot[name] != nil:
+			names = append(names, aws.String(param.Name))
+		case syncOpts.Delete:
+			deleteNames = append(deleteNames, aws.String(param.Name))
+		}
+	}
+	sort.Slice(deleteNames, func(i, j int) bool { return *deleteNames[i] < *deleteNames[j] })
+	return names, deleteNames, nil
+}
@@ -2,12 +2,36 @@ package main
 
 import (
 	"os"
+	"os/exec"
+	"syscall"
+
+	"github.com/m4i/ssmenv/lib"
 )
 
+// exitChangesPending is the exit status of --dry-run with pending changes.
+const exitChangesPending = 2
+
+// exitSignaled is added to the number of the signal which killed the command, as shells do.
+const exitSignaled = 128
+
 var version string
 
 func main() {
 	if err := (CLI{}).Run(os.Args); err != nil {
+		if err == lib.ErrChangesPending {
+			os.Exit(exitChangesPending)
+		}
+		// exec --redact-output exits with the status of the command.
+		if e, ok := err.(*exec.ExitError); ok {
+			if status, isWait := e.Sys().(syscall.WaitStatus); isWait {
+				if status.Exited() {
+					os.Exit(status.ExitStatus())
+				}
+				if status.Signaled() {
+					os.Exit(exitSignaled + int(status.Signal()))
+				}
+			}
+		}
 		os.Exit(1)
 	}
 }